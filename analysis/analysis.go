@@ -0,0 +1,339 @@
+// Package analysis finds regular expressions that make backtracking
+// matchers run in exponential or polynomial time (ReDoS).
+//
+// Analyze walks the Regexp tree looking for the constructs behind
+// catastrophic backtracking: quantified subexpressions that can match
+// a repeated string in more than one way, and adjacent quantifiers that
+// can share input. Every finding is confirmed by matching a witness
+// string and comes with an attack string of the form
+// prefix + pump*n + suffix.
+//
+// Compile simplifies patterns and may remove the very constructs that
+// are looked for, as in (a+)+ => (a+). Pass the tree returned by
+// regexp.Parse to analyze a pattern as written.
+//
+// The analysis is a heuristic. It reports no false positives for the
+// constructs it checks, but it may miss vulnerabilities hidden behind
+// more involved patterns.
+package analysis
+
+import (
+	"strings"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+// A Severity describes how the running time of a backtracking matcher
+// grows with the number of pumps in the attack string.
+type Severity int
+
+const (
+	Polynomial  Severity = 1 + iota // at least quadratic
+	Exponential                     // doubles with every pump
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Polynomial:
+		return "polynomial"
+	case Exponential:
+		return "exponential"
+	}
+	return ""
+}
+
+// A Kind is the construct an Issue was found in.
+type Kind int
+
+const (
+	NestedQuantifier       Kind = 1 + iota // (a+)+: the body of a quantifier matches a repeated string
+	AmbiguousAlternation                   // (a|a)*: two branches of a quantified alternation share a string
+	OverlappingQuantifiers                 // a*a*: adjacent quantifiers share a string
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NestedQuantifier:
+		return "nested quantifier"
+	case AmbiguousAlternation:
+		return "ambiguous alternation"
+	case OverlappingQuantifiers:
+		return "overlapping quantifiers"
+	}
+	return ""
+}
+
+// An Issue is a vulnerable subexpression together with an attack string.
+type Issue struct {
+	Severity Severity
+	Kind     Kind
+	Node     *regexp.Regexp // the vulnerable subexpression
+	Prefix   string         // leads a match up to Node
+	Pump     string         // matched by Node in more than one way when repeated
+	Suffix   string         // makes the overall match fail
+}
+
+// Attack returns an attack string pumping Node n times.
+func (is Issue) Attack(n int) string {
+	return is.Prefix + strings.Repeat(is.Pump, n) + is.Suffix
+}
+
+// suffixCandidates are tried in turn as the rune that makes a match fail.
+const suffixCandidates = "!\n\x00~#;=@"
+
+// An analyzer holds the state of a single analysis.
+type analyzer struct {
+	suffix string
+	issues []Issue
+}
+
+// Analyze reports the subexpressions of re that can make a backtracking
+// matcher run in more than linear time, outermost first.
+func Analyze(re *regexp.Regexp) []Issue {
+	a := &analyzer{suffix: failSuffix(re)}
+	a.walk(re, "")
+	return a.issues
+}
+
+// walk visits re, which a match reaches after consuming prefix.
+func (a *analyzer) walk(re *regexp.Regexp, prefix string) {
+	switch re.Op {
+	case regexp.OpRepeat:
+		if re.Max == -1 {
+			a.checkRepeat(re, prefix)
+		}
+		a.walk(re.Sub[0], prefix)
+	case regexp.OpConcat:
+		a.checkConcat(re, prefix)
+		for _, sub := range re.Sub {
+			a.walk(sub, prefix)
+			w, _ := shortest(sub, false)
+			prefix += w
+		}
+	case regexp.OpAlternate, regexp.OpCapture:
+		for _, sub := range re.Sub {
+			a.walk(sub, prefix)
+		}
+	}
+}
+
+// checkRepeat looks for a string that the body of the unbounded repeat
+// re matches both in one iteration and in two.
+func (a *analyzer) checkRepeat(re *regexp.Regexp, prefix string) {
+	body := unwrap(re.Sub[0])
+	if body.Op == regexp.OpAlternate {
+		for j, b0 := range body.Sub {
+			for _, b1 := range body.Sub[j+1:] {
+				if w, ok := common(b0, b1); ok {
+					a.report(Exponential, AmbiguousAlternation, re, prefix, w)
+					return
+				}
+			}
+		}
+	}
+	twice := &regexp.Regexp{Op: regexp.OpConcat, Sub: []*regexp.Regexp{body, body}}
+	ws := candidates(body)
+	for _, w0 := range ws {
+		for _, w1 := range ws {
+			if w := w0 + w1; matches(body, w) && matches(twice, w) {
+				kind := NestedQuantifier
+				if body.Op == regexp.OpAlternate {
+					kind = AmbiguousAlternation
+				}
+				a.report(Exponential, kind, re, prefix, w)
+				return
+			}
+		}
+	}
+}
+
+// checkConcat looks for two unbounded repeats in the concatenation re
+// that are separated by nullable subexpressions only and share a string.
+func (a *analyzer) checkConcat(re *regexp.Regexp, prefix string) {
+	for j, sub0 := range re.Sub {
+		w0, _ := shortest(sub0, false)
+		if r0 := unwrap(sub0); r0.Op == regexp.OpRepeat && r0.Max == -1 {
+			for _, sub1 := range re.Sub[j+1:] {
+				r1 := unwrap(sub1)
+				if r1.Op == regexp.OpRepeat && r1.Max == -1 {
+					if w, ok := common(r0.Sub[0], r1.Sub[0]); ok {
+						a.report(Polynomial, OverlappingQuantifiers, re, prefix, w)
+						return
+					}
+				}
+				if w, _ := shortest(sub1, false); w != "" {
+					break
+				}
+			}
+		}
+		prefix += w0
+	}
+}
+
+func (a *analyzer) report(sev Severity, kind Kind, re *regexp.Regexp, prefix, pump string) {
+	a.issues = append(a.issues, Issue{
+		Severity: sev,
+		Kind:     kind,
+		Node:     re,
+		Prefix:   prefix,
+		Pump:     pump,
+		Suffix:   a.suffix,
+	})
+}
+
+// unwrap strips capture groups off re.
+func unwrap(re *regexp.Regexp) *regexp.Regexp {
+	for re.Op == regexp.OpCapture {
+		re = re.Sub[0]
+	}
+	return re
+}
+
+// common returns a non-empty string matched by both re0 and re1.
+func common(re0, re1 *regexp.Regexp) (string, bool) {
+	for _, w := range candidates(re0) {
+		if matches(re0, w) && matches(re1, w) {
+			return w, true
+		}
+	}
+	for _, w := range candidates(re1) {
+		if matches(re0, w) && matches(re1, w) {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+// candidates returns short non-empty strings that re is likely
+// to match: single runes that can start a match and its shortest
+// non-empty example.
+func candidates(re *regexp.Regexp) []string {
+	var ws []string
+	for _, r := range firstRunes(re) {
+		ws = append(ws, string(r))
+	}
+	if w, ok := shortest(re, true); ok && w != "" {
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// shortest returns a shortest string matched by re, ignoring
+// assertions. If nonEmpty is set, repeats are taken at least once.
+// It fails if re contains an empty character class.
+func shortest(re *regexp.Regexp, nonEmpty bool) (string, bool) {
+	switch re.Op {
+	case regexp.OpLiteral:
+		return string(re.Sym), true
+	case regexp.OpCharClass:
+		if len(re.Sym) == 0 {
+			return "", false
+		}
+		return string(re.Sym[0]), true
+	case regexp.OpCapture:
+		return shortest(re.Sub[0], nonEmpty)
+	case regexp.OpConcat:
+		var b strings.Builder
+		for _, sub := range re.Sub {
+			w, ok := shortest(sub, nonEmpty)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(w)
+		}
+		return b.String(), true
+	case regexp.OpAlternate:
+		best, found := "", false
+		for _, sub := range re.Sub {
+			if w, ok := shortest(sub, nonEmpty); ok && (!found || len(w) < len(best)) {
+				best, found = w, true
+			}
+		}
+		return best, found
+	case regexp.OpRepeat:
+		n := re.Min
+		if nonEmpty && n == 0 && re.Max != 0 {
+			n = 1
+		}
+		w, ok := shortest(re.Sub[0], nonEmpty)
+		return strings.Repeat(w, n), ok || n == 0
+	}
+	return "", true
+}
+
+// firstRunes returns one rune of every literal and character class
+// that can consume the first rune of a match of re.
+func firstRunes(re *regexp.Regexp) []rune {
+	switch re.Op {
+	case regexp.OpLiteral, regexp.OpCharClass:
+		if len(re.Sym) == 0 {
+			return nil
+		}
+		return []rune{re.Sym[0]}
+	case regexp.OpCapture, regexp.OpRepeat:
+		if re.Max == 0 && re.Op == regexp.OpRepeat {
+			return nil
+		}
+		return firstRunes(re.Sub[0])
+	case regexp.OpAlternate:
+		var rs []rune
+		for _, sub := range re.Sub {
+			rs = append(rs, firstRunes(sub)...)
+		}
+		return rs
+	case regexp.OpConcat:
+		var rs []rune
+		for _, sub := range re.Sub {
+			rs = append(rs, firstRunes(sub)...)
+			if w, _ := shortest(sub, false); w != "" {
+				break
+			}
+		}
+		return rs
+	}
+	return nil
+}
+
+// matches reports whether re matches all of w.
+func matches(re *regexp.Regexp, w string) bool {
+	if strings.ContainsRune(w, '\n') {
+		return false
+	}
+	full := &regexp.Regexp{Op: regexp.OpConcat, Sub: []*regexp.Regexp{
+		{Op: regexp.OpLineStart}, re, {Op: regexp.OpLineEnd}, {Op: regexp.OpAccept},
+	}}
+	return full.MatchString(w, false)
+}
+
+// failSuffix returns a rune that no literal or class of re matches,
+// or an empty string if there is none among the candidates.
+func failSuffix(re *regexp.Regexp) string {
+	var leaves []*regexp.Regexp
+	var collect func(re *regexp.Regexp)
+	collect = func(re *regexp.Regexp) {
+		if re.Op == regexp.OpLiteral || re.Op == regexp.OpCharClass {
+			leaves = append(leaves, re)
+		}
+		for _, sub := range re.Sub {
+			collect(sub)
+		}
+	}
+	collect(re)
+Candidates:
+	for _, r := range suffixCandidates {
+		for _, leaf := range leaves {
+			if leaf.Op == regexp.OpLiteral {
+				if strings.ContainsRune(string(leaf.Sym), r) {
+					continue Candidates
+				}
+				continue
+			}
+			wrap := &regexp.Regexp{Op: regexp.OpConcat, Sub: []*regexp.Regexp{leaf, {Op: regexp.OpAccept}}}
+			if wrap.MatchString(string(r), false) {
+				continue Candidates
+			}
+		}
+		return string(r)
+	}
+	return ""
+}
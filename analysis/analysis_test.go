@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	stdregexp "regexp"
+	"testing"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+func TestAnalyze(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		severity Severity
+		kind     Kind
+		pump     string
+	}{
+		{"^(a+)+$", Exponential, NestedQuantifier, "aa"},
+		{"^(a*)*$", Exponential, NestedQuantifier, "aa"},
+		{"^(a|a)*$", Exponential, AmbiguousAlternation, "a"},
+		{"^(a|ab|b)*c", Exponential, AmbiguousAlternation, "ab"},
+		{"^(\\w+\\d*)+!", Exponential, NestedQuantifier, "00"},
+		{"^x(a*b*)+y", Exponential, NestedQuantifier, "aa"},
+		{"^\\d+\\d+$", Polynomial, OverlappingQuantifiers, "0"},
+		{"^a*b?a*$", Polynomial, OverlappingQuantifiers, "a"},
+		{"^[a-z]+=[0-9]+$", 0, 0, ""},
+		{"^(ab)+$", 0, 0, ""},
+		{"^(a|b)*$", 0, 0, ""},
+		{"^a*ba*$", 0, 0, ""},
+		{"^(a+b)+$", 0, 0, ""},
+	} {
+		re, err := regexp.Parse(test.pattern)
+		if err != nil {
+			t.Fatalf("error: %q: %v", test.pattern, err)
+		}
+		issues := Analyze(re)
+		if test.severity == 0 {
+			if len(issues) != 0 {
+				t.Errorf("error: %q\ngot: %v\nwant: no issues", test.pattern, issues)
+			}
+			continue
+		}
+		if len(issues) == 0 {
+			t.Errorf("error: %q\ngot: no issues\nwant: %v %v", test.pattern, test.severity, test.kind)
+			continue
+		}
+		is := issues[0]
+		if is.Severity != test.severity || is.Kind != test.kind || is.Pump != test.pump {
+			t.Errorf("error: %q\ngot: %v %v %q\nwant: %v %v %q", test.pattern,
+				is.Severity, is.Kind, is.Pump, test.severity, test.kind, test.pump)
+		}
+	}
+}
+
+func TestAttack(t *testing.T) {
+	for _, test := range []struct {
+		pattern, prefix, suffix string
+	}{
+		{"^(a+)+$", "", "!"},
+		{"^x(a|a)*y", "x", "!"},
+		{"^\\d+\\d+$", "", "!"},
+		{"^!(a+)+$", "!", "\n"},
+	} {
+		re, _ := regexp.Parse(test.pattern)
+		issues := Analyze(re)
+		if len(issues) == 0 {
+			t.Fatalf("error: %q: no issues", test.pattern)
+		}
+		is := issues[0]
+		if is.Prefix != test.prefix || is.Suffix != test.suffix {
+			t.Errorf("error: %q\ngot: %q %q\nwant: %q %q", test.pattern,
+				is.Prefix, is.Suffix, test.prefix, test.suffix)
+		}
+		if attack := is.Attack(20); stdregexp.MustCompile(test.pattern).MatchString(attack) {
+			t.Errorf("error: %q matches attack %q", test.pattern, attack)
+		}
+	}
+}
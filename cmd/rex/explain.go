@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+// explain prints a plain English description of each pattern in args
+// and returns the exit status.
+func explain(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: rex explain pattern ...")
+		return 2
+	}
+	for j, expr := range args {
+		text, err := regexp.Explain(expr)
+		if err != nil {
+			fmt.Fprintln(stderr, "rex:", err)
+			return 2
+		}
+		if len(args) > 1 {
+			if j > 0 {
+				fmt.Fprintln(stdout)
+			}
+			fmt.Fprintf(stdout, "%s\n", expr)
+		}
+		io.WriteString(stdout, text)
+	}
+	return 0
+}
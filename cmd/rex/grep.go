@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+// ANSI escapes used to highlight the output.
+const (
+	colorMatch = "\x1b[1;31m"
+	colorName  = "\x1b[35m"
+	colorLine  = "\x1b[32m"
+	colorSep   = "\x1b[36m"
+	colorReset = "\x1b[0m"
+)
+
+// A grep holds the options and the state of a search.
+type grep struct {
+	re         *regexp.Regexp
+	ignoreCase bool
+	only       bool // print the matched parts only
+	count      bool // print counts only
+	invert     bool // select non-matching lines
+	color      bool
+	names      bool // prefix lines with file names
+	before     int  // lines of context before a selected line
+	after      int  // lines of context after a selected line
+
+	out    *bufio.Writer
+	stderr io.Writer
+
+	selected bool // whether any line was selected
+	failed   bool // whether an error occurred
+}
+
+// A line is a numbered input line without its line terminator.
+type line struct {
+	num  int
+	text string
+}
+
+// search searches the file or directory at path,
+// reading stdin if path is -.
+func (g *grep) search(path string, stdin io.Reader, recursive bool) {
+	if path == "-" {
+		g.file("(standard input)", stdin)
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		g.error(err)
+		return
+	}
+	if !info.IsDir() {
+		g.open(path)
+		return
+	}
+	if !recursive {
+		g.error(fmt.Errorf("%s: is a directory", path))
+		return
+	}
+	filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			g.error(err)
+		} else if d.Type().IsRegular() {
+			g.open(name)
+		}
+		return nil
+	})
+}
+
+func (g *grep) open(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		g.error(err)
+		return
+	}
+	defer f.Close()
+	g.file(name, f)
+}
+
+func (g *grep) error(err error) {
+	g.out.Flush()
+	fmt.Fprintln(g.stderr, "rex:", err)
+	g.failed = true
+}
+
+// file searches the lines read from r, reporting them as name.
+func (g *grep) file(name string, r io.Reader) {
+	in := bufio.NewReader(r)
+	var (
+		count   int
+		pending []line // context lines before the next selected line
+		left    int    // context lines still to print after a selected line
+		last    int    // number of the last printed line
+	)
+	for num := 1; ; num++ {
+		text, err := in.ReadString('\n')
+		if text == "" && err != nil {
+			if err != io.EOF {
+				g.error(fmt.Errorf("%s: %v", name, err))
+			}
+			break
+		}
+		text = strings.TrimSuffix(text, "\n")
+		l := line{num, text}
+		if g.re.MatchString(text, g.ignoreCase) == g.invert {
+			if left > 0 {
+				g.print(name, l, '-')
+				last = num
+				left--
+			} else if g.before > 0 {
+				if len(pending) == g.before {
+					pending = pending[1:]
+				}
+				pending = append(pending, l)
+			}
+			continue
+		}
+		count++
+		g.selected = true
+		if g.count {
+			continue
+		}
+		if g.only {
+			g.printMatches(name, l)
+			continue
+		}
+		if len(pending) > 0 {
+			l0 := pending[0]
+			if last > 0 && l0.num > last+1 {
+				g.separator()
+			}
+			for _, p := range pending {
+				g.print(name, p, '-')
+			}
+			pending = pending[:0]
+		} else if last > 0 && num > last+1 && g.before+g.after > 0 {
+			g.separator()
+		}
+		g.print(name, l, ':')
+		last = num
+		left = g.after
+	}
+	if g.count {
+		if g.names {
+			g.prefix(name, colorName, ':')
+		}
+		fmt.Fprintln(g.out, count)
+	}
+}
+
+// prefix writes s in color followed by the separator sep.
+func (g *grep) prefix(s, color string, sep byte) {
+	if g.color {
+		fmt.Fprintf(g.out, "%s%s%s%s%c%s", color, s, colorReset, colorSep, sep, colorReset)
+		return
+	}
+	g.out.WriteString(s)
+	g.out.WriteByte(sep)
+}
+
+// head writes the file name and line number of l.
+func (g *grep) head(name string, l line, sep byte) {
+	if g.names {
+		g.prefix(name, colorName, sep)
+	}
+	g.prefix(strconv.Itoa(l.num), colorLine, sep)
+}
+
+// print writes l, highlighting its matches unless it is a context
+// line or the selection is inverted.
+func (g *grep) print(name string, l line, sep byte) {
+	g.head(name, l, sep)
+	if !g.color || g.invert || sep != ':' {
+		g.out.WriteString(l.text)
+		g.out.WriteByte('\n')
+		return
+	}
+	pos := 0
+	for _, m := range g.re.FindAllStringIndex(l.text, -1, g.ignoreCase) {
+		if m[0] == m[1] {
+			continue
+		}
+		g.out.WriteString(l.text[pos:m[0]])
+		g.out.WriteString(colorMatch + l.text[m[0]:m[1]] + colorReset)
+		pos = m[1]
+	}
+	g.out.WriteString(l.text[pos:])
+	g.out.WriteByte('\n')
+}
+
+// printMatches writes every non-empty match in l on a line of its own.
+func (g *grep) printMatches(name string, l line) {
+	if g.invert {
+		return
+	}
+	for _, m := range g.re.FindAllStringIndex(l.text, -1, g.ignoreCase) {
+		if m[0] == m[1] {
+			continue
+		}
+		g.head(name, l, ':')
+		if g.color {
+			g.out.WriteString(colorMatch + l.text[m[0]:m[1]] + colorReset + "\n")
+		} else {
+			g.out.WriteString(l.text[m[0]:m[1]] + "\n")
+		}
+	}
+}
+
+// separator writes the line between non-adjacent groups of context.
+func (g *grep) separator() {
+	if g.color {
+		g.out.WriteString(colorSep + "--" + colorReset + "\n")
+		return
+	}
+	g.out.WriteString("--\n")
+}
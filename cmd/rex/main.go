@@ -0,0 +1,127 @@
+// Rex searches files for lines matching a regular expression,
+// using the syntax and engines of the rex regexp package.
+//
+// Usage:
+//
+//	rex [flags] pattern [file ...]
+//	rex explain pattern ...
+//
+// The explain command describes each pattern in plain English instead
+// of searching; use -- to search for the pattern "explain" itself.
+//
+// With no files, or a file named -, rex reads standard input.
+// Directories are searched with -r only.
+//
+// The flags are:
+//
+//	-i        ignore case
+//	-o        print only the matched parts of matching lines
+//	-c        print only a count of matching lines per file
+//	-v        select non-matching lines
+//	-r        search directories recursively
+//	-A n      print n lines of context after matching lines
+//	-B n      print n lines of context before matching lines
+//	-C n      print n lines of context before and after matching lines
+//	-color    highlight matches: auto, always or never
+//
+// The exit status is 0 if a line is selected, 1 if no line is
+// selected and 2 if an error occurred.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes rex with the arguments args
+// and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "explain" {
+		return explain(args[1:], stdout, stderr)
+	}
+	flags := flag.NewFlagSet("rex", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: rex [flags] pattern [file ...]")
+		fmt.Fprintln(stderr, "       rex explain pattern ...")
+		flags.PrintDefaults()
+	}
+	g := &grep{}
+	flags.BoolVar(&g.ignoreCase, "i", false, "ignore case")
+	flags.BoolVar(&g.only, "o", false, "print only the matched parts of matching lines")
+	flags.BoolVar(&g.count, "c", false, "print only a count of matching lines per file")
+	flags.BoolVar(&g.invert, "v", false, "select non-matching lines")
+	recursive := flags.Bool("r", false, "search directories recursively")
+	flags.IntVar(&g.after, "A", 0, "print `n` lines of context after matching lines")
+	flags.IntVar(&g.before, "B", 0, "print `n` lines of context before matching lines")
+	context := flags.Int("C", 0, "print `n` lines of context before and after matching lines")
+	color := flags.String("color", "auto", "highlight matches: auto, always or never")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	re, err := regexp.Compile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "rex:", err)
+		return 2
+	}
+	g.re = re
+	if *context > 0 {
+		g.before = max(g.before, *context)
+		g.after = max(g.after, *context)
+	}
+	switch *color {
+	case "always":
+		g.color = true
+	case "never":
+	case "auto":
+		g.color = isTerminal(stdout)
+	default:
+		fmt.Fprintf(stderr, "rex: invalid -color %q\n", *color)
+		return 2
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	g.out = out
+	g.stderr = stderr
+
+	paths := flags.Args()[1:]
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	g.names = len(paths) > 1 || *recursive
+	for _, path := range paths {
+		g.search(path, stdin, *recursive)
+	}
+	switch {
+	case g.failed:
+		return 2
+	case g.selected:
+		return 0
+	}
+	return 1
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const poem = `one
+two
+three
+four
+five
+six
+seven
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(poem), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("Seven\neight\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "sub", "b.txt")
+
+	for _, test := range []struct {
+		args   []string
+		stdin  string
+		want   string
+		status int
+	}{
+		{[]string{"o"}, poem, "1:one\n2:two\n4:four\n", 0},
+		{[]string{"-i", "^s"}, "Six\nten\nseven\n", "1:Six\n3:seven\n", 0},
+		{[]string{"-o", "e+"}, "three\nfive\n", "1:ee\n2:e\n", 0},
+		{[]string{"-c", "e"}, poem, "4\n", 0},
+		{[]string{"-v", "e"}, poem, "2:two\n4:four\n6:six\n", 0},
+		{[]string{"-c", "-v", "."}, poem, "0\n", 1},
+		{[]string{"z"}, poem, "", 1},
+		{[]string{"-A", "1", "^f"}, poem, "4:four\n5:five\n6-six\n", 0},
+		{[]string{"-B", "1", "six"}, poem, "5-five\n6:six\n", 0},
+		{[]string{"-C", "1", "^t|seven"}, poem, "1-one\n2:two\n3:three\n4-four\n--\n6-six\n7:seven\n", 0},
+		{[]string{"-color=always", "e+"}, "three\n", "\x1b[32m1\x1b[0m\x1b[36m:\x1b[0mthr\x1b[1;31mee\x1b[0m\n", 0},
+		{[]string{"-color=always", "-o", "w"}, "two\n", "\x1b[32m1\x1b[0m\x1b[36m:\x1b[0m\x1b[1;31mw\x1b[0m\n", 0},
+		{[]string{"seven", a}, "", "7:seven\n", 0},
+		{[]string{"-i", "seven", a, "-"}, "SEVEN\n", a + ":7:seven\n(standard input):1:SEVEN\n", 0},
+		{[]string{"-c", "e", a, b}, "", a + ":4\n" + b + ":2\n", 0},
+		{[]string{"-r", "-i", "^seven", dir}, "", a + ":7:seven\n" + b + ":1:Seven\n", 0},
+		{[]string{"e", dir}, "", "", 2},
+		{[]string{"e", filepath.Join(dir, "missing")}, "", "", 2},
+		{[]string{"a{2,1}"}, "", "", 2},
+		{[]string{"a|"}, "", "", 2},
+		{[]string{"[a"}, "", "", 2},
+		{[]string{}, "", "", 2},
+		{[]string{"-color=sometimes", "e"}, "", "", 2},
+		{[]string{"explain", "^\\d+"}, "", "start of line\none or more of: digit\n", 0},
+		{[]string{"explain", "a", "b|c"}, "", "a\nthe character \"a\"\n\nb|c\neither:\n  the character \"b\"\nor:\n  the character \"c\"\n", 0},
+		{[]string{"explain", "(a"}, "", "", 2},
+		{[]string{"explain"}, "", "", 2},
+		{[]string{"--", "explain"}, "explain\n", "1:explain\n", 0},
+	} {
+		var stdout, stderr strings.Builder
+		status := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if got := stdout.String(); got != test.want || status != test.status {
+			t.Errorf("error: %q\ngot: %q %d\nwant: %q %d", test.args, got, status, test.want, test.status)
+		}
+		if status == 2 && stderr.Len() == 0 {
+			t.Errorf("error: %q: no message on stderr", test.args)
+		}
+	}
+}
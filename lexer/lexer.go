@@ -0,0 +1,120 @@
+// Package lexer builds tokenizers from ordered lists of regular
+// expression rules.
+//
+// All rules are compiled into a single automaton. At every position the
+// rule with the longest match wins, ties are broken in favor of the rule
+// that was added first. Input no rule matches is reported as error tokens.
+package lexer
+
+import (
+	"iter"
+	"unicode/utf8"
+
+	"github.com/tautastic/rex/regexp"
+)
+
+// ErrorKind is the Kind of tokens covering input no rule matches.
+const ErrorKind = -1
+
+// A Token is a single lexeme of the input.
+type Token struct {
+	Kind   int    // index of the matching rule or ErrorKind
+	Name   string // name of the matching rule or "error"
+	Text   string
+	Offset int // byte offset of the token in the input
+	Line   int // 1-based line number
+	Column int // 1-based column in runes
+}
+
+// A Lexer splits input into tokens according to an ordered list of rules.
+type Lexer struct {
+	names    []string
+	patterns []string
+	set      *regexp.Set
+	i        bool
+}
+
+// New returns an empty Lexer. If i is set, rules match case-insensitively.
+func New(i bool) *Lexer {
+	return &Lexer{i: i}
+}
+
+// Rule adds a rule with the given name and pattern. Rules added earlier
+// take precedence over later ones when their matches are equally long.
+// Rule returns l so that calls can be chained.
+func (l *Lexer) Rule(name, pattern string) *Lexer {
+	l.names = append(l.names, name)
+	l.patterns = append(l.patterns, pattern)
+	l.set = nil
+	return l
+}
+
+// Compile compiles the rules into a single automaton. It is called by
+// Tokens if needed, but calling it upfront reports invalid patterns early.
+func (l *Lexer) Compile() error {
+	set, err := regexp.CompileSet(l.patterns)
+	if err != nil {
+		return err
+	}
+	l.set = set
+	return nil
+}
+
+// Tokens returns an iterator over the tokens of input. Runs of input no
+// rule matches are yielded as tokens of kind ErrorKind. Rules matching
+// the empty string are ignored at positions where nothing else matches.
+// Tokens panics if the rules do not compile.
+func (l *Lexer) Tokens(input string) iter.Seq[Token] {
+	if l.set == nil {
+		if err := l.Compile(); err != nil {
+			panic(err)
+		}
+	}
+	return func(yield func(Token) bool) {
+		line, col := 1, 1
+		errStart, errLine, errCol := -1, 0, 0
+		emit := func(kind int, name string, start, end, line, col int) bool {
+			return yield(Token{Kind: kind, Name: name, Text: input[start:end],
+				Offset: start, Line: line, Column: col})
+		}
+		for pos := 0; pos < len(input); {
+			kind, end := l.set.LongestMatch(input, pos, l.i)
+			if kind < 0 || end == pos {
+				if errStart < 0 {
+					errStart, errLine, errCol = pos, line, col
+				}
+				_, width := utf8.DecodeRuneInString(input[pos:])
+				line, col = advance(input[pos:pos+width], line, col)
+				pos += width
+				continue
+			}
+			if errStart >= 0 {
+				if !emit(ErrorKind, "error", errStart, pos, errLine, errCol) {
+					return
+				}
+				errStart = -1
+			}
+			if !emit(kind, l.names[kind], pos, end, line, col) {
+				return
+			}
+			line, col = advance(input[pos:end], line, col)
+			pos = end
+		}
+		if errStart >= 0 {
+			emit(ErrorKind, "error", errStart, len(input), errLine, errCol)
+		}
+	}
+}
+
+// advance returns the line and column following text
+// when text starts at line and col.
+func advance(text string, line, col int) (int, int) {
+	for _, r := range text {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
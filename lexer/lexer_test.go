@@ -0,0 +1,62 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	l := New(false).
+		Rule("if", "if").
+		Rule("ident", "[a-zA-Z_]\\w*").
+		Rule("number", "\\d+").
+		Rule("op", "[=<>]|==|<=|>=").
+		Rule("space", "\\s+")
+	if err := l.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for tok := range l.Tokens("if iffy <= 42\n  x == ∑7") {
+		if tok.Name == "space" {
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s:%q@%d:%d", tok.Name, tok.Text, tok.Line, tok.Column))
+	}
+	want := []string{
+		`if:"if"@1:1`,
+		`ident:"iffy"@1:4`,
+		`op:"<="@1:9`,
+		`number:"42"@1:12`,
+		`ident:"x"@2:3`,
+		`op:"=="@2:5`,
+		`error:"∑"@2:8`,
+		`number:"7"@2:9`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("error:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTokensCompileError(t *testing.T) {
+	l := New(false).Rule("bad", "(a")
+	if err := l.Compile(); err == nil {
+		t.Errorf("error: expected an error for \"(a\"")
+	}
+}
+
+func TestTokensStopEarly(t *testing.T) {
+	l := New(true).Rule("word", "[a-z]+").Rule("space", " ")
+	n := 0
+	for tok := range l.Tokens("ONE two three") {
+		if tok.Text != "ONE" {
+			t.Errorf("error:\ngot: %q\nwant: %q", tok.Text, "ONE")
+		}
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("error:\ngot: %d tokens\nwant: 1", n)
+	}
+}
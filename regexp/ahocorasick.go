@@ -0,0 +1,226 @@
+package regexp
+
+// minAhoCorasickLiterals is the smallest alternation of literals
+// that is matched with an Aho-Corasick automaton instead of the engines.
+const minAhoCorasickLiterals = 8
+
+// maxAhoCorasickLiterals bounds the number of literals
+// put into a single automaton.
+const maxAhoCorasickLiterals = 1 << 12
+
+// An AhoCorasick is an automaton that searches for many literal strings
+// at once in a single pass over the input.
+//
+// Among the literals matching at the leftmost position, it reports the
+// first one in the order given to NewAhoCorasick, or the longest one
+// after a call to Longest.
+type AhoCorasick struct {
+	literals []string
+	nodes    []acNode
+	root     [256]int32 // transitions of the root node, 0 if missing
+	longest  bool
+}
+
+type acNode struct {
+	next  map[byte]int32
+	fail  int32
+	depth int
+	out   []int32 // literals ending here, including those of the fail chain
+}
+
+// NewAhoCorasick returns an automaton searching for the given literals.
+// Empty literals are ignored.
+func NewAhoCorasick(literals []string) *AhoCorasick {
+	ac := &AhoCorasick{literals: literals, nodes: []acNode{{}}}
+	for i, lit := range literals {
+		if lit == "" {
+			continue
+		}
+		n := int32(0)
+		for j := 0; j < len(lit); j++ {
+			next, ok := ac.nodes[n].next[lit[j]]
+			if !ok {
+				next = int32(len(ac.nodes))
+				ac.nodes = append(ac.nodes, acNode{depth: j + 1})
+				if ac.nodes[n].next == nil {
+					ac.nodes[n].next = make(map[byte]int32)
+				}
+				ac.nodes[n].next[lit[j]] = next
+			}
+			n = next
+		}
+		ac.nodes[n].out = append(ac.nodes[n].out, int32(i))
+	}
+
+	for b, next := range ac.nodes[0].next {
+		ac.root[b] = next
+	}
+
+	// Compute the failure links breadth-first.
+	queue := []int32{0}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for b, child := range ac.nodes[n].next {
+			queue = append(queue, child)
+			fail := int32(0)
+			if n != 0 {
+				fail = ac.delta(ac.nodes[n].fail, b)
+			}
+			ac.nodes[child].fail = fail
+			ac.nodes[child].out = append(ac.nodes[child].out, ac.nodes[fail].out...)
+		}
+	}
+	return ac
+}
+
+// Longest makes future searches prefer the longest literal
+// among those matching at the leftmost position.
+func (ac *AhoCorasick) Longest() {
+	ac.longest = true
+}
+
+// delta returns the node reached from n on the byte b.
+func (ac *AhoCorasick) delta(n int32, b byte) int32 {
+	for n != 0 {
+		if next, ok := ac.nodes[n].next[b]; ok {
+			return next
+		}
+		n = ac.nodes[n].fail
+	}
+	return ac.root[b]
+}
+
+// FindIndex returns the leftmost match in str at or after pos as
+// the byte offsets of the match followed by the index of the
+// matching literal, or nil if there is no match.
+func (ac *AhoCorasick) FindIndex(str string, pos int) []int {
+	return ac.findIndex(str, pos, call{})
+}
+
+// findIndex is FindIndex run as part of the call c.
+func (ac *AhoCorasick) findIndex(str string, pos int, c call) []int {
+	start, end, id := -1, -1, -1
+	n := int32(0)
+	for ; pos < len(str); pos++ {
+		c.tick()
+		n = ac.delta(n, str[pos])
+		for _, i := range ac.nodes[n].out {
+			s := pos + 1 - len(ac.literals[i])
+			switch {
+			case start < 0 || s < start,
+				s == start && !ac.longest && int(i) < id,
+				s == start && ac.longest && pos+1 > end:
+				start, end, id = s, pos+1, int(i)
+			}
+		}
+		if start >= 0 && pos+1-ac.nodes[n].depth > start {
+			// No later match can start at or before start.
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	return []int{start, end, id}
+}
+
+// FindAllIndex returns at most n successive non-overlapping matches
+// in str as returned by FindIndex. If n < 0, it returns all matches.
+func (ac *AhoCorasick) FindAllIndex(str string, n int) [][]int {
+	var result [][]int
+	for pos := 0; n < 0 || len(result) < n; {
+		m := ac.FindIndex(str, pos)
+		if m == nil {
+			break
+		}
+		result = append(result, m)
+		pos = m[1]
+	}
+	return result
+}
+
+// literalAlternation returns the strings matched by re if re is an
+// alternation of at least minAhoCorasickLiterals literal strings without
+// captures. Common prefixes factored out by the simplifier are
+// distributed over the branches again, in their original order.
+func literalAlternation(re *Regexp) []string {
+	lits, ok := literalStrings(re)
+	if !ok || len(lits) < minAhoCorasickLiterals {
+		return nil
+	}
+	for _, lit := range lits {
+		if lit == "" {
+			return nil
+		}
+	}
+	return lits
+}
+
+// literalStrings returns the strings matched by re in order of
+// preference if re consists of literals, small character classes,
+// concatenations and alternations only, and matches at most
+// maxAhoCorasickLiterals strings.
+func literalStrings(re *Regexp) ([]string, bool) {
+	switch re.Op {
+	case OpLiteral:
+		return []string{string(re.Sym)}, true
+	case OpCharClass:
+		// The runes of a class are distinct strings of equal length,
+		// so their order does not matter.
+		var lits []string
+		for i := 0; i < len(re.Sym); i += 2 {
+			for r := re.Sym[i]; r <= re.Sym[i+1]; r++ {
+				if len(lits) == maxClassLiterals {
+					return nil, false
+				}
+				lits = append(lits, string(r))
+			}
+		}
+		return lits, true
+	case OpAccept:
+		return []string{""}, true
+	case OpConcat:
+		lits := []string{""}
+		for _, sub := range re.Sub {
+			next, ok := literalStrings(sub)
+			if !ok || len(lits)*len(next) > maxAhoCorasickLiterals {
+				return nil, false
+			}
+			var prod []string
+			for _, l0 := range lits {
+				for _, l1 := range next {
+					prod = append(prod, l0+l1)
+				}
+			}
+			lits = prod
+		}
+		return lits, true
+	case OpAlternate:
+		var lits []string
+		for _, sub := range re.Sub {
+			next, ok := literalStrings(sub)
+			if !ok || len(lits)+len(next) > maxAhoCorasickLiterals {
+				return nil, false
+			}
+			lits = append(lits, next...)
+		}
+		return lits, true
+	}
+	return nil, false
+}
+
+// getAhoCorasick returns the Aho-Corasick automaton of re if re is a
+// large alternation of literals, building it if necessary, or nil.
+// The automaton never ignores case.
+func (re *Regexp) getAhoCorasick() *AhoCorasick {
+	re.acOnce.Do(func() {
+		if lits := literalAlternation(re); lits != nil {
+			re.ac = NewAhoCorasick(lits)
+			if re.longest {
+				re.ac.Longest()
+			}
+		}
+	})
+	return re.ac
+}
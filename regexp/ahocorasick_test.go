@@ -0,0 +1,79 @@
+package regexp
+
+import (
+	"fmt"
+	stdregexp "regexp"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	for _, test := range []struct {
+		literals []string
+		input    string
+		longest  bool
+		want     string
+	}{
+		{[]string{"he", "she", "his", "hers"}, "ushers", false, "[1 4 1]"},
+		{[]string{"a", "ab", "abc"}, "xabcx", false, "[1 2 0]"},
+		{[]string{"a", "ab", "abc"}, "xabcx", true, "[1 4 2]"},
+		{[]string{"abcd", "bc"}, "abce", false, "[1 3 1]"},
+		{[]string{"b", "abc"}, "abc", false, "[0 3 1]"},
+		{[]string{"samwise", "sam"}, "samwix", false, "[0 3 1]"},
+		{[]string{"∑x", "∑"}, "a∑x", true, "[1 5 0]"},
+		{[]string{"foo"}, "bar", false, "[]"},
+	} {
+		ac := NewAhoCorasick(test.literals)
+		if test.longest {
+			ac.Longest()
+		}
+		if got := fmt.Sprint(ac.FindIndex(test.input, 0)); got != test.want {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v",
+				test.literals, test.input, got, test.want)
+		}
+	}
+}
+
+func TestAhoCorasickAlternation(t *testing.T) {
+	words := []string{"foo", "bar", "baz", "qux", "quux", "corge", "grault",
+		"garply", "waldo", "fred", "plugh", "xyzzy", "thud", "ba", "barbaz"}
+	pattern := strings.Join(words, "|")
+	input := "a foo walked into a barbaz, quux said: thud xyzzy waldorf"
+
+	re := FromInfixExp(pattern)
+	if re.getAhoCorasick() == nil {
+		t.Fatalf("error: %q is not matched with Aho-Corasick", pattern)
+	}
+	std := stdregexp.MustCompile(pattern)
+	if got, want := re.FindAllStringIndex(input, -1, false),
+		std.FindAllStringIndex(input, -1); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("error:\ngot: %v\nwant: %v", got, want)
+	}
+	if !re.MatchString(input, false) || re.MatchString("nothing here", false) {
+		t.Errorf("error: MatchString disagrees with Aho-Corasick")
+	}
+
+	re.Longest()
+	std.Longest()
+	if got, want := re.FindAllString(input, -1, false),
+		std.FindAllString(input, -1); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("error: longest\ngot: %v\nwant: %v", got, want)
+	}
+}
+
+func BenchmarkAhoCorasick(b *testing.B) {
+	b.StopTimer()
+	var words []string
+	for i := 0; i < 2000; i++ {
+		words = append(words, fmt.Sprintf("word%dx", i))
+	}
+	re := FromInfixExp(strings.Join(words, "|"))
+	input := strings.Repeat("some ordinary text without keywords ", 1000) + "word1999x"
+	b.SetBytes(int64(len(input)))
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if !re.MatchString(input, false) {
+			b.Fatal("no match")
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package regexp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// maxAutomatonStates bounds the number of states of an Automaton.
+	maxAutomatonStates = 1 << 14
+	// maxAutomatonRegexp bounds the number of nodes of the tree
+	// built by Automaton.Regexp.
+	maxAutomatonRegexp = 1 << 16
+)
+
+var (
+	// ErrTooManyStates is returned by NewAutomaton if the automaton of
+	// a pattern grows beyond its state limit.
+	ErrTooManyStates = errors.New("regexp: automaton exceeds maximum number of states")
+	// ErrRegexpTooLarge is returned by Automaton.Regexp if the tree
+	// of an automaton grows beyond its size limit.
+	ErrRegexpTooLarge = errors.New("regexp: automaton converts to too large an expression")
+)
+
+// An Automaton is a fully built deterministic automaton accepting the
+// strings a pattern matches, that is the strings for which MatchString
+// reports true without ignoring case.
+//
+// Its alphabet is a partition of the runes into classes that no rune
+// range of the pattern splits, as computed by alphabet. Class c
+// holds the runes from lo[c] up to lo[c+1]-1, or up to utf8.MaxRune for
+// the last class. State 0 is the start state.
+type Automaton struct {
+	lo     []rune
+	next   [][]int // next[s][c] is the state reached from s on class c
+	accept []bool  // accept[s] reports whether strings leading to s match
+}
+
+// NewAutomaton builds the automaton of re. It returns ErrTooManyStates
+// if the automaton grows too large.
+func NewAutomaton(re *Regexp) (*Automaton, error) {
+	p := re.getProg()
+	d := newDFA(p, p.Start, false, false)
+	d.fold = false // an automaton never ignores case
+	a := &Automaton{lo: alphabet(p)}
+	ids := make(map[*dfaState]int)
+	var states []*dfaState
+	add := func(s *dfaState) int {
+		id, ok := ids[s]
+		if !ok {
+			id = len(states)
+			ids[s] = id
+			states = append(states, s)
+		}
+		return id
+	}
+	add(d.intern(nil, runeFlags(endOfText), nil))
+	for id := 0; id < len(states); id++ {
+		if len(states) > maxAutomatonStates {
+			return nil, ErrTooManyStates
+		}
+		s := states[id]
+		next := make([]int, len(a.lo))
+		for c, r := range a.lo {
+			next[c] = add(d.next(s, r))
+		}
+		a.next = append(a.next, next)
+		a.accept = append(a.accept, d.next(s, endOfText).flags&flagMatched != 0)
+	}
+	return a, nil
+}
+
+// alphabet returns the lower bounds of the rune classes of p in
+// increasing order. The runes of a class are matched by the same
+// instructions, are all newlines or none, and, if p has word boundary
+// assertions, are all word characters or none.
+func alphabet(p *Prog) []rune {
+	bounds := []rune{0, '\n', '\n' + 1, 0xD800, 0xE000}
+	addRanges := func(rr RuneRange) {
+		if len(rr) == 1 {
+			bounds = append(bounds, rr[0], rr[0]+1)
+			return
+		}
+		for i := 0; i+1 < len(rr); i += 2 {
+			bounds = append(bounds, rr[i], rr[i+1]+1)
+		}
+	}
+	word := false
+	for _, i := range p.Inst {
+		switch i.Op {
+		case InstRune:
+			addRanges(i.Rune)
+		case InstEmptyWidth:
+			word = word || EmptyOp(i.Arg)&(EmptyWordBoundary|EmptyNoWordBoundary) != 0
+		}
+	}
+	if word {
+		addRanges(PerlClass['w'])
+	}
+	return mergeBounds(bounds)
+}
+
+// mergeBounds sorts bounds and removes duplicates
+// and runes beyond utf8.MaxRune.
+func mergeBounds(bounds []rune) []rune {
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	for len(bounds) > 0 && bounds[len(bounds)-1] > utf8.MaxRune {
+		bounds = bounds[:len(bounds)-1]
+	}
+	return bounds
+}
+
+// class returns the class of r.
+func (a *Automaton) class(r rune) int {
+	return sort.Search(len(a.lo), func(c int) bool { return a.lo[c] > r }) - 1
+}
+
+// NumStates returns the number of states of a.
+func (a *Automaton) NumStates() int {
+	return len(a.next)
+}
+
+// Accepts reports whether a accepts str.
+func (a *Automaton) Accepts(str string) bool {
+	s := 0
+	for _, r := range str {
+		s = a.next[s][a.class(r)]
+	}
+	return a.accept[s]
+}
+
+// Shortest returns the shortest string a accepts, choosing the lowest
+// rune of every class, and reports whether there is one.
+func (a *Automaton) Shortest() (string, bool) {
+	type from struct {
+		state int
+		r     rune
+	}
+	prev := make([]from, len(a.next))
+	seen := make([]bool, len(a.next))
+	seen[0] = true
+	queue := []int{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if a.accept[s] {
+			var rs []rune
+			for ; s != 0; s = prev[s].state {
+				rs = append(rs, prev[s].r)
+			}
+			slices.Reverse(rs)
+			return string(rs), true
+		}
+		for c, t := range a.next[s] {
+			if !seen[t] && utf8.ValidRune(a.lo[c]) {
+				seen[t] = true
+				prev[t] = from{s, a.lo[c]}
+				queue = append(queue, t)
+			}
+		}
+	}
+	return "", false
+}
+
+// Complement returns an automaton accepting the strings a rejects.
+func Complement(a *Automaton) *Automaton {
+	c := &Automaton{lo: a.lo, next: a.next, accept: make([]bool, len(a.accept))}
+	for s, ok := range a.accept {
+		c.accept[s] = !ok
+	}
+	return c
+}
+
+// Intersect returns an automaton accepting the strings
+// both a and b accept.
+func Intersect(a, b *Automaton) *Automaton {
+	return combine(a, b, func(x, y bool) bool { return x && y })
+}
+
+// Union returns an automaton accepting the strings
+// a or b accepts.
+func Union(a, b *Automaton) *Automaton {
+	return combine(a, b, func(x, y bool) bool { return x || y })
+}
+
+// Equivalent reports whether a and b accept the same strings. If not,
+// it also returns a shortest string accepted by only one of them.
+func Equivalent(a, b *Automaton) (bool, string) {
+	s, ok := combine(a, b, func(x, y bool) bool { return x != y }).Shortest()
+	return !ok, s
+}
+
+// Subset reports whether b accepts every string a accepts. If not,
+// it also returns a shortest string accepted by a but not by b.
+func Subset(a, b *Automaton) (bool, string) {
+	s, ok := combine(a, b, func(x, y bool) bool { return x && !y }).Shortest()
+	return !ok, s
+}
+
+// combine returns the product of a and b over the common refinement
+// of their alphabets, accepting where f of their acceptances holds.
+func combine(a, b *Automaton, f func(x, y bool) bool) *Automaton {
+	p := &Automaton{lo: mergeBounds(append(slices.Clone(a.lo), b.lo...))}
+	ids := map[[2]int]int{{0, 0}: 0}
+	pairs := [][2]int{{0, 0}}
+	for id := 0; id < len(pairs); id++ {
+		sa, sb := pairs[id][0], pairs[id][1]
+		next := make([]int, len(p.lo))
+		for c, r := range p.lo {
+			t := [2]int{a.next[sa][a.class(r)], b.next[sb][b.class(r)]}
+			n, ok := ids[t]
+			if !ok {
+				n = len(pairs)
+				ids[t] = n
+				pairs = append(pairs, t)
+			}
+			next[c] = n
+		}
+		p.next = append(p.next, next)
+		p.accept = append(p.accept, f(a.accept[sa], b.accept[sb]))
+	}
+	return p
+}
+
+// Regexp converts a into a tree matching exactly the strings a accepts
+// when matched against a whole string, by eliminating the states of
+// the minimal automaton one by one. For the automaton of a pattern
+// without assertions, which accepts every string containing a match,
+// the tree also matches the same strings as the pattern. Eliminating
+// states may grow the tree exponentially, so Regexp returns
+// ErrRegexpTooLarge once it exceeds its size limit, or if the printed
+// tree does not compile within DefaultLimits.
+func (a *Automaton) Regexp() (*Regexp, error) {
+	m := a.Minimize()
+	n := len(m.next)
+
+	// live[s] reports whether an accepting state is reachable from s.
+	live := make([]bool, n)
+	for changed := true; changed; {
+		changed = false
+		for s, next := range m.next {
+			if live[s] {
+				continue
+			}
+			live[s] = m.accept[s]
+			for _, t := range next {
+				live[s] = live[s] || live[t]
+			}
+			changed = changed || live[s]
+		}
+	}
+	if !live[0] {
+		return acceptRegexp(&Regexp{Op: OpCharClass, Sym: RuneRange{}}), nil
+	}
+
+	// edge[s][t] matches the runes moving from s to t, with the
+	// added initial state n and final state n+1. size[s][t] is the
+	// number of nodes of edge[s][t], counting shared subtrees once
+	// per use as the printed pattern does.
+	edge := make([][]*Regexp, n+2)
+	size := make([][]int, n+2)
+	for s := range edge {
+		edge[s] = make([]*Regexp, n+2)
+		size[s] = make([]int, n+2)
+	}
+	for s, next := range m.next {
+		if !live[s] {
+			continue
+		}
+		for c, t := range next {
+			if !live[t] {
+				continue
+			}
+			hi := rune(utf8.MaxRune)
+			if c+1 < len(m.lo) {
+				hi = m.lo[c+1] - 1
+			}
+			if edge[s][t] == nil {
+				edge[s][t] = &Regexp{Op: OpCharClass}
+				size[s][t] = 1
+			}
+			edge[s][t].Sym = appendRange(edge[s][t].Sym, m.lo[c], hi)
+		}
+		if m.accept[s] {
+			edge[s][n+1] = emptyRegexp()
+			size[s][n+1] = 1
+		}
+	}
+	edge[n][0] = emptyRegexp()
+	size[n][0] = 1
+
+	// Eliminate the states with the fewest paths through them first.
+	left := make([]bool, n)
+	for s := range left {
+		left[s] = live[s]
+	}
+	for {
+		q, best := -1, 0
+		for s := range n {
+			if !left[s] {
+				continue
+			}
+			in, out := 0, 0
+			for t := range n + 2 {
+				if t != s && edge[t][s] != nil {
+					in++
+				}
+				if t != s && edge[s][t] != nil {
+					out++
+				}
+			}
+			if q < 0 || in*out < best {
+				q, best = s, in*out
+			}
+		}
+		if q < 0 {
+			break
+		}
+		left[q] = false
+		var loop *Regexp
+		loopSize := 0
+		if edge[q][q] != nil {
+			loop = &Regexp{Op: OpRepeat, Min: 0, Max: -1, Sub: []*Regexp{edge[q][q]}}
+			loopSize = 1 + size[q][q]
+		}
+		for p := range n + 2 {
+			if p == q || edge[p][q] == nil {
+				continue
+			}
+			for r := range n + 2 {
+				if r == q || edge[q][r] == nil {
+					continue
+				}
+				path := &Regexp{Op: OpConcat, Sub: []*Regexp{edge[p][q]}}
+				if loop != nil {
+					path.Sub = append(path.Sub, loop)
+				}
+				path.Sub = append(path.Sub, edge[q][r])
+				pathSize := 1 + size[p][q] + loopSize + size[q][r]
+				if edge[p][r] == nil {
+					edge[p][r], size[p][r] = path, pathSize
+				} else {
+					edge[p][r] = &Regexp{Op: OpAlternate, Sub: []*Regexp{edge[p][r], path}}
+					size[p][r] += 1 + pathSize
+				}
+				if size[p][r] > maxAutomatonRegexp {
+					return nil, ErrRegexpTooLarge
+				}
+			}
+			edge[p][q] = nil
+		}
+		for r := range n + 2 {
+			edge[q][r] = nil
+		}
+	}
+	re := simplifyRegexp(optionalEmpty(simplify(copyRegexp(edge[n][n+1]))))
+	if _, err := Compile(re.String()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRegexpTooLarge, err)
+	}
+	return re, nil
+}
+
+// optionalEmpty rewrites the alternations of re with an empty branch,
+// which the parser does not accept, as in (|x) => (x)?.
+func optionalEmpty(re *Regexp) *Regexp {
+	for j, sub := range re.Sub {
+		re.Sub[j] = optionalEmpty(sub)
+	}
+	if re.Op != OpAlternate || !slices.ContainsFunc(re.Sub, isEmpty) {
+		return re
+	}
+	re.Sub = slices.DeleteFunc(re.Sub, isEmpty)
+	switch len(re.Sub) {
+	case 0:
+		return emptyRegexp()
+	case 1:
+		return &Regexp{Op: OpRepeat, Min: 0, Max: 1, Sub: re.Sub}
+	}
+	return &Regexp{Op: OpRepeat, Min: 0, Max: 1, Sub: []*Regexp{re}}
+}
+
+// copyRegexp returns a deep copy of the tree re, whose subtrees may be
+// shared, so that it can be simplified in place.
+func copyRegexp(re *Regexp) *Regexp {
+	c := &Regexp{Op: re.Op, Min: re.Min, Max: re.Max, Cap: re.Cap, Sym: re.Sym}
+	for _, sub := range re.Sub {
+		c.Sub = append(c.Sub, copyRegexp(sub))
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package regexp
+
+import (
+	"sync"
+	"testing"
+)
+
+func mustAutomaton(t *testing.T, expr string) *Automaton {
+	t.Helper()
+	re, err := Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAutomaton(re)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAutomatonAccepts(t *testing.T) {
+	for _, expr := range []string{
+		"^a+$", "ab|cd", "^[a-c]{2}x?$", `\bfoo\b`, `(x|yz)+q$`, `^\d+-\w*$`, "^$", "a.c",
+	} {
+		a := mustAutomaton(t, expr)
+		re, _ := Compile(expr)
+		for _, str := range []string{
+			"", "a", "aa", "ab", "cd", "xcdy", "abx", "bcx", "foo", "a foo.", "food", "xq", "yzxq", "yzxqz",
+			"12-ab", "1-", "-", "abc", "a\nc", "aéc",
+		} {
+			if got, want := a.Accepts(str), re.MatchString(str, false); got != want {
+				t.Errorf("error: %q accepts %q\ngot: %v\nwant: %v", expr, str, got, want)
+			}
+		}
+	}
+}
+
+func TestSubset(t *testing.T) {
+	for _, test := range []struct {
+		a, b    string
+		ok      bool
+		witness string
+	}{
+		{"^ab+$", "^a[a-z]*$", true, ""},
+		{"^a[a-z]*$", "^ab+$", false, "a"},
+		{"^(foo|bar)$", "^[a-z]{3}$", true, ""},
+		{"^[a-z]{3}$", "^(foo|bar)$", false, "aaa"},
+		{"^x$", "x", true, ""},
+		{"x", "^x$", false, "\x00x"},
+	} {
+		ok, witness := Subset(mustAutomaton(t, test.a), mustAutomaton(t, test.b))
+		if ok != test.ok || witness != test.witness {
+			t.Errorf("error: Subset(%q, %q)\ngot: %v %q\nwant: %v %q", test.a, test.b, ok, witness, test.ok, test.witness)
+		}
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	for _, test := range []struct {
+		a, b    string
+		ok      bool
+		witness string
+	}{
+		{"^(a|b)*$", "^[ab]*$", true, ""},
+		{"x", "^.*x", true, ""},
+		{"^a{2,3}$", "^aaa?$", true, ""},
+		{`\bfoo`, "foo", false, "0foo"},
+		{"^a*$", "^a+$", false, ""},
+	} {
+		ok, witness := Equivalent(mustAutomaton(t, test.a), mustAutomaton(t, test.b))
+		if ok != test.ok || witness != test.witness {
+			t.Errorf("error: Equivalent(%q, %q)\ngot: %v %q\nwant: %v %q", test.a, test.b, ok, witness, test.ok, test.witness)
+		}
+	}
+}
+
+func TestLanguageOps(t *testing.T) {
+	abc, bcd := mustAutomaton(t, "^[a-c]+$"), mustAutomaton(t, "^[b-d]+$")
+	both := Intersect(abc, bcd)
+	either := Union(abc, bcd)
+	neither := Complement(either)
+	for _, test := range []struct {
+		str                   string
+		both, either, neither bool
+	}{
+		{"", false, false, true},
+		{"a", false, true, false},
+		{"bc", true, true, false},
+		{"d", false, true, false},
+		{"ad", false, false, true},
+		{"e", false, false, true},
+	} {
+		if got := both.Accepts(test.str); got != test.both {
+			t.Errorf("error: Intersect accepts %q\ngot: %v\nwant: %v", test.str, got, test.both)
+		}
+		if got := either.Accepts(test.str); got != test.either {
+			t.Errorf("error: Union accepts %q\ngot: %v\nwant: %v", test.str, got, test.either)
+		}
+		if got := neither.Accepts(test.str); got != test.neither {
+			t.Errorf("error: Complement accepts %q\ngot: %v\nwant: %v", test.str, got, test.neither)
+		}
+	}
+	if s, ok := both.Shortest(); !ok || s != "b" {
+		t.Errorf("error: Shortest\ngot: %q %v\nwant: %q %v", s, ok, "b", true)
+	}
+	if s, ok := Intersect(abc, Complement(abc)).Shortest(); ok {
+		t.Errorf("error: Shortest of an empty language\ngot: %q %v\nwant: %q %v", s, ok, "", false)
+	}
+}
+
+func TestAutomatonTooLarge(t *testing.T) {
+	re, _ := Compile("a[ab]{14}$")
+	if _, err := NewAutomaton(re); err != ErrTooManyStates {
+		t.Errorf("error: NewAutomaton\ngot: %v\nwant: %v", err, ErrTooManyStates)
+	}
+}
+
+// TestAutomatonFold checks that building an automaton does not change
+// how searches ignoring case running at the same time match.
+// TestAutomatonFold shares a Regexp between goroutines building its
+// automaton and matching it with and without ignoring case.
+func TestAutomatonFold(t *testing.T) {
+	re, _ := Compile("a")
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				switch g % 3 {
+				case 0:
+					if a, _ := NewAutomaton(re); a.Accepts("A") {
+						t.Errorf("error: automaton of %q accepts %q", "a", "A")
+					}
+				case 1:
+					if !re.MatchString("xA", true) {
+						t.Errorf("error: %q does not match %q ignoring case", "a", "xA")
+					}
+				case 2:
+					if re.MatchString("xA", false) {
+						t.Errorf("error: %q matches %q", "a", "xA")
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
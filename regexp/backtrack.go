@@ -0,0 +1,192 @@
+package regexp
+
+import "unicode/utf8"
+
+const (
+	// maxBacktrackProg is the largest program run by the backtracker.
+	maxBacktrackProg = 500
+	// maxBacktrackVector is the size in bits of the largest visited
+	// set the backtracker may allocate.
+	maxBacktrackVector = 256 * 1024
+)
+
+// A job is a pending branch of the backtracker. For InstAlt, arg
+// means the second branch is next; for InstCapture, it means the
+// capture slot is restored to pos.
+type job struct {
+	pc  uint32
+	arg bool
+	pos int
+}
+
+// A bitState holds the state of the backtracker. Every pair of
+// instruction and position is explored at most once, so the running
+// time is bounded by the size of the visited set.
+type bitState struct {
+	call
+	p        *Prog
+	str      string
+	start    int
+	longest  bool
+	cap      []int
+	matchcap []int
+	matched  bool
+	jobs     []job
+	visited  []uint32
+}
+
+// shouldBacktrack reports whether the backtracker may run the
+// program of re over n bytes of input.
+func (re *Regexp) shouldBacktrack(n int) bool {
+	p := re.getProg()
+	return len(p.Inst) <= maxBacktrackProg && len(p.Inst)*(n+1) <= maxBacktrackVector
+}
+
+// doBacktrack finds the leftmost match in str at or after pos by
+// backtracking. It returns the positions of the match followed by the
+// positions of the first ncap capture groups, or nil if there is no
+// match. The caller must check shouldBacktrack first.
+func (re *Regexp) doBacktrack(str string, pos int, ncap int, c call) []int {
+	p := re.getProg()
+	n := len(p.Inst) * (len(str) - pos + 1)
+	b := &bitState{
+		call:     c,
+		p:        p,
+		str:      str,
+		start:    pos,
+		longest:  re.longest,
+		cap:      make([]int, 2*(ncap+1)),
+		matchcap: make([]int, 2*(ncap+1)),
+		visited:  make([]uint32, (n+31)/32),
+	}
+	for {
+		for i := range b.cap {
+			b.cap[i] = -1
+		}
+		b.trace(p, EventStart, uint32(p.Start), pos, 0)
+		if b.try(uint32(p.Start), pos) {
+			return b.matchcap
+		}
+		_, width := step(str, pos)
+		if width == 0 {
+			return nil
+		}
+		pos += width
+	}
+}
+
+// shouldVisit reports whether pc at pos has not been explored yet
+// and marks it as explored.
+func (b *bitState) shouldVisit(pc uint32, pos int) bool {
+	n := uint(int(pc)*(len(b.str)-b.start+1) + pos - b.start)
+	if b.visited[n/32]&(1<<(n&31)) != 0 {
+		return false
+	}
+	b.visited[n/32] |= 1 << (n & 31)
+	return true
+}
+
+func (b *bitState) push(pc uint32, pos int, arg bool) {
+	if b.p.Inst[pc].Op != InstFail && (arg || b.shouldVisit(pc, pos)) {
+		b.jobs = append(b.jobs, job{pc: pc, arg: arg, pos: pos})
+	}
+}
+
+// try runs the program anchored at pos and reports whether it matched.
+// The positions of the match are left in b.matchcap.
+func (b *bitState) try(pc uint32, pos int) bool {
+	b.jobs = b.jobs[:0]
+	b.push(pc, pos, false)
+	for len(b.jobs) > 0 {
+		b.tick()
+		j := b.jobs[len(b.jobs)-1]
+		b.jobs = b.jobs[:len(b.jobs)-1]
+		pc, pos, arg := j.pc, j.pos, j.arg
+		if arg && b.p.Inst[pc].Op == InstAlt {
+			b.trace(b.p, EventBacktrack, pc, pos, 0)
+		}
+		goto Skip
+
+	CheckAndLoop:
+		b.tick()
+		if !b.shouldVisit(pc, pos) {
+			continue
+		}
+	Skip:
+		i := &b.p.Inst[pc]
+		switch i.Op {
+		case InstFail:
+			continue
+		case InstAlt:
+			if arg {
+				arg = false
+				pc = i.Arg
+				goto CheckAndLoop
+			}
+			b.trace(b.p, EventEnter, pc, pos, 0)
+			b.push(pc, pos, true)
+			pc = i.Out
+			goto CheckAndLoop
+		case InstRune:
+			r, w := step(b.str, pos)
+			if !i.matchRune(r, b.fold) {
+				b.trace(b.p, EventFail, pc, pos, r)
+				continue
+			}
+			b.trace(b.p, EventConsume, pc, pos, r)
+			pos += w
+			pc = i.Out
+			goto CheckAndLoop
+		case InstCapture:
+			if arg {
+				b.cap[i.Arg] = pos
+				continue
+			}
+			b.trace(b.p, EventEnter, pc, pos, 0)
+			if int(i.Arg) < len(b.cap) {
+				b.push(pc, b.cap[i.Arg], true)
+				b.cap[i.Arg] = pos
+			}
+			pc = i.Out
+			goto CheckAndLoop
+		case InstEmptyWidth:
+			if EmptyOp(i.Arg)&^b.context(pos) != 0 {
+				b.trace(b.p, EventAssertFail, pc, pos, 0)
+				continue
+			}
+			b.trace(b.p, EventAssert, pc, pos, 0)
+			pc = i.Out
+			goto CheckAndLoop
+		case InstNop:
+			b.trace(b.p, EventEnter, pc, pos, 0)
+			pc = i.Out
+			goto CheckAndLoop
+		case InstMatch:
+			b.trace(b.p, EventAccept, pc, pos, 0)
+			b.cap[1] = pos
+			if !b.longest {
+				copy(b.matchcap, b.cap)
+				return true
+			}
+			if !b.matched || b.matchcap[1] < pos {
+				copy(b.matchcap, b.cap)
+				b.matched = true
+			}
+			if pos == len(b.str) {
+				// No longer match is possible.
+				return true
+			}
+		}
+	}
+	return b.longest && b.matched
+}
+
+// context returns the empty-width conditions satisfied at pos.
+func (b *bitState) context(pos int) EmptyOp {
+	r0 := endOfText
+	if pos > 0 {
+		r0, _ = utf8.DecodeLastRuneInString(b.str[:pos])
+	}
+	r1, _ := step(b.str, pos)
+	return emptyOpContext(r0, r1)
+}
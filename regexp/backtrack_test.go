@@ -0,0 +1,48 @@
+package regexp
+
+import (
+	stdregexp "regexp"
+	"strings"
+	"testing"
+)
+
+func TestBacktrack(t *testing.T) {
+	for _, test := range matchTests {
+		re := FromInfixExp(test.pattern)
+		std := stdregexp.MustCompile("(?m)" + test.pattern)
+		want := std.FindStringSubmatchIndex(test.input)
+		got := re.doBacktrack(test.input, 0, re.NumSubexp(), call{})
+		if !intsEqual(got, want) {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+		re.Longest()
+		std.Longest()
+		want = std.FindStringSubmatchIndex(test.input)
+		if got := re.doBacktrack(test.input, 0, 0, call{}); !intsEqual(got, want[:min(2, len(want))]) {
+			t.Errorf("error: longest %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+	}
+}
+
+func TestBacktrackExponential(t *testing.T) {
+	// A naive backtracker takes 2^n steps here.
+	re := FromInfixExp("(a*)*b")
+	input := strings.Repeat("a", 1000)
+	if !re.shouldBacktrack(len(input)) {
+		t.Fatalf("error: input of %d bytes not backtracked", len(input))
+	}
+	if got := re.doBacktrack(input, 0, 1, call{}); got != nil {
+		t.Errorf("error:\ngot: %v\nwant: %v", got, nil)
+	}
+}
+
+func TestShouldBacktrack(t *testing.T) {
+	re := FromInfixExp("[a-z]+=\\d+")
+	n := len(re.getProg().Inst)
+	if !re.shouldBacktrack(maxBacktrackVector/n - 1) {
+		t.Errorf("error: input within budget not backtracked")
+	}
+	if re.shouldBacktrack(maxBacktrackVector / n) {
+		t.Errorf("error: input over budget backtracked")
+	}
+}
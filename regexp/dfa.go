@@ -0,0 +1,406 @@
+package regexp
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// dfaMaxStates bounds the number of states in the DFA cache.
+	dfaMaxStates = 4096
+	// dfaMinBytesPerState is the minimum number of bytes a search has to
+	// advance per cached state between two cache resets. Slower progress
+	// means the cache is thrashing and the search falls back to the NFA.
+	dfaMinBytesPerState = 10
+)
+
+// mark separates the threads started at different positions
+// in the instruction list of a leftmost-longest DFA state.
+const mark int32 = -1
+
+const (
+	flagWord    uint8 = 1 << iota // the previous rune is a word character
+	flagLine                      // the previous rune is a newline or there is none
+	flagMatch                     // a match ends before the previous rune
+	flagMatched                   // a match was seen, no new threads are started
+)
+
+// A dfaState is a set of NFA threads in priority order together
+// with the context of the previous rune.
+type dfaState struct {
+	insts   []int32 // pending instructions, closure not yet computed
+	flags   uint8
+	matches []uint32 // patterns whose match ends before the previous rune, set mode only
+	ascii   [utf8.RuneSelf]*dfaState
+	next    map[rune]*dfaState
+}
+
+// A dfa is a lazily constructed deterministic automaton for a program.
+// States are built on demand during a search and kept in a bounded cache.
+type dfa struct {
+	p        *Prog
+	startPC  int32
+	anchored bool
+	longest  bool
+	set      bool // report every matching pattern of a Set
+	fold     bool // ignore case, fixed since the states depend on it
+	reversed bool // run over the input backwards
+	states   map[string]*dfaState
+	visited  *queue
+	added    *queue
+	stack    []int32
+}
+
+func newDFA(p *Prog, start int, anchored, longest bool) *dfa {
+	return &dfa{
+		p:        p,
+		startPC:  int32(start),
+		anchored: anchored,
+		longest:  longest,
+		states:   make(map[string]*dfaState),
+		visited:  newQueue(len(p.Inst)),
+		added:    newQueue(len(p.Inst)),
+	}
+}
+
+// A dfaPool hands out the DFAs of a program, one to each search
+// running at a time, since a DFA adds states to its cache as it
+// searches. Returned DFAs keep their caches for later searches.
+type dfaPool struct {
+	mu       sync.Mutex
+	free     []*dfa // DFAs not ignoring case
+	freeFold []*dfa // DFAs ignoring case
+	new      func() *dfa
+}
+
+// list returns the free DFAs ignoring case if fold is set.
+func (dp *dfaPool) list(fold bool) *[]*dfa {
+	if fold {
+		return &dp.freeFold
+	}
+	return &dp.free
+}
+
+// get takes a DFA ignoring case if fold is set from the pool,
+// building one if none is free.
+func (dp *dfaPool) get(fold bool) *dfa {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	free := dp.list(fold)
+	if n := len(*free); n > 0 {
+		d := (*free)[n-1]
+		*free = (*free)[:n-1]
+		return d
+	}
+	d := dp.new()
+	d.fold = fold
+	return d
+}
+
+// put returns d to the pool.
+func (dp *dfaPool) put(d *dfa) {
+	dp.mu.Lock()
+	free := dp.list(d.fold)
+	*free = append(*free, d)
+	dp.mu.Unlock()
+}
+
+// search runs d.search on a DFA of the pool.
+func (dp *dfaPool) search(str string, pos int, earliest bool, c call) (end int, matched bool, ok bool) {
+	d := dp.get(c.fold)
+	defer dp.put(d)
+	return d.search(str, pos, earliest, c)
+}
+
+// A dfaRun tracks a single search of a DFA over an input.
+type dfaRun struct {
+	call
+	d         *dfa
+	s         *dfaState
+	lastReset int
+}
+
+// start begins a search at pos. Unless the DFA is anchored,
+// the initial state holds no threads yet. A reversed DFA takes
+// the rune at pos as the previous one.
+func (d *dfa) start(str string, pos int, c call) *dfaRun {
+	prev := endOfText
+	if d.reversed {
+		prev, _ = step(str, pos)
+	} else if pos > 0 {
+		prev, _ = utf8.DecodeLastRuneInString(str[:pos])
+	}
+	var insts []int32
+	if d.anchored {
+		insts = []int32{d.startPC}
+	}
+	return &dfaRun{call: c, d: d, s: d.intern(insts, runeFlags(prev), nil), lastReset: -1}
+}
+
+// step moves the search on the rune r at pos and returns the new state.
+// It returns nil if the state cache thrashes.
+func (run *dfaRun) step(r rune, pos int) *dfaState {
+	d, s := run.d, run.s
+	ns := s.transition(r)
+	if ns == nil {
+		if len(d.states) >= dfaMaxStates {
+			if run.lastReset >= 0 && abs(pos-run.lastReset) < dfaMinBytesPerState*dfaMaxStates {
+				return nil
+			}
+			run.lastReset = pos
+			d.reset()
+			s = d.intern(s.insts, s.flags, s.matches)
+		}
+		ns = d.next(s, r)
+	}
+	run.s = ns
+	return ns
+}
+
+// search runs the DFA over str starting at pos. It returns the end of
+// the match and whether there was one. With earliest set, search stops
+// at the first position where a match ends. If the state cache thrashes,
+// search gives up and returns ok == false.
+func (d *dfa) search(str string, pos int, earliest bool, c call) (end int, matched bool, ok bool) {
+	run := d.start(str, pos, c)
+	end = -1
+	for {
+		run.tick()
+		r, w := step(str, pos)
+		s := run.step(r, pos)
+		if s == nil {
+			return -1, false, false
+		}
+		if s.flags&flagMatch != 0 {
+			end = pos
+			if earliest {
+				return end, true, true
+			}
+		}
+		if w == 0 || len(s.insts) == 0 && (d.anchored || s.flags&flagMatched != 0) {
+			break
+		}
+		pos += w
+	}
+	return end, end >= 0, true
+}
+
+// searchSet runs a set mode DFA over all of str and marks the
+// patterns that match somewhere in seen. It stops early once every
+// pattern has matched. If the state cache thrashes, searchSet gives
+// up and returns false.
+func (d *dfa) searchSet(str string, seen []bool, c call) bool {
+	run := d.start(str, 0, c)
+	left := len(seen)
+	for pos := 0; ; {
+		run.tick()
+		r, w := step(str, pos)
+		s := run.step(r, pos)
+		if s == nil {
+			return false
+		}
+		for _, id := range s.matches {
+			if !seen[id] {
+				seen[id] = true
+				left--
+			}
+		}
+		if w == 0 || left == 0 {
+			return true
+		}
+		pos += w
+	}
+}
+
+// searchLongestSet runs an anchored set mode DFA over str starting at
+// pos. It returns the pattern with the longest match, preferring the
+// lowest index among equally long ones, and the end of that match.
+// If the state cache thrashes, searchLongestSet gives up and returns
+// ok == false.
+func (d *dfa) searchLongestSet(str string, pos int, c call) (id, end int, ok bool) {
+	run := d.start(str, pos, c)
+	id, end = -1, -1
+	for {
+		run.tick()
+		r, w := step(str, pos)
+		s := run.step(r, pos)
+		if s == nil {
+			return -1, -1, false
+		}
+		if len(s.matches) > 0 {
+			id, end = int(slices.Min(s.matches)), pos
+		}
+		if w == 0 || len(s.insts) == 0 {
+			return id, end, true
+		}
+		pos += w
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// runeFlags returns the context flags describing r as the previous rune.
+func runeFlags(r rune) uint8 {
+	var flags uint8
+	if isWordChar(r) {
+		flags |= flagWord
+	}
+	if r == endOfText || r == '\n' {
+		flags |= flagLine
+	}
+	return flags
+}
+
+func (s *dfaState) transition(r rune) *dfaState {
+	if 0 <= r && r < utf8.RuneSelf {
+		return s.ascii[r]
+	}
+	return s.next[r]
+}
+
+func (d *dfa) reset() {
+	d.states = make(map[string]*dfaState)
+}
+
+// intern returns the cached state for insts and flags,
+// creating it if necessary.
+func (d *dfa) intern(insts []int32, flags uint8, matches []uint32) *dfaState {
+	var b strings.Builder
+	b.WriteByte(flags)
+	writeUint32(&b, uint32(len(insts)))
+	for _, pc := range insts {
+		writeUint32(&b, uint32(pc))
+	}
+	for _, id := range matches {
+		writeUint32(&b, id)
+	}
+	key := b.String()
+	if s, ok := d.states[key]; ok {
+		return s
+	}
+	s := &dfaState{insts: insts, flags: flags, matches: matches}
+	d.states[key] = s
+	return s
+}
+
+func writeUint32(b *strings.Builder, x uint32) {
+	b.WriteByte(byte(x))
+	b.WriteByte(byte(x >> 8))
+	b.WriteByte(byte(x >> 16))
+	b.WriteByte(byte(x >> 24))
+}
+
+// next computes and caches the state reached from s on the rune r.
+func (d *dfa) next(s *dfaState, r rune) *dfaState {
+	var cond EmptyOp = EmptyNoWordBoundary
+	if s.flags&flagLine != 0 {
+		cond |= EmptyLineStart
+	}
+	if r == endOfText || r == '\n' {
+		cond |= EmptyLineEnd
+	}
+	if (s.flags&flagWord != 0) != isWordChar(r) {
+		cond ^= EmptyWordBoundary | EmptyNoWordBoundary
+	}
+
+	insts := s.insts
+	if !d.anchored && (d.set || s.flags&flagMatched == 0) {
+		// Start a new thread with the lowest priority.
+		insts = append(append(insts[:len(insts):len(insts)], mark), d.startPC)
+	}
+
+	d.visited.clear()
+	d.added.clear()
+	var next []int32
+	var matches []uint32
+	matched := false
+Insts:
+	for _, pc := range insts {
+		if pc == mark {
+			if matched {
+				// Leftmost-longest: drop the threads started later.
+				break
+			}
+			if d.longest && len(next) > 0 && next[len(next)-1] != mark {
+				next = append(next, mark)
+			}
+			continue
+		}
+		d.stack = append(d.stack[:0], pc)
+		for len(d.stack) > 0 {
+			pc := d.stack[len(d.stack)-1]
+			d.stack = d.stack[:len(d.stack)-1]
+			if pc == 0 || d.visited.contains(uint32(pc)) {
+				continue
+			}
+			d.visited.add(uint32(pc))
+			i := &d.p.Inst[pc]
+			switch i.Op {
+			case InstAlt:
+				d.stack = append(d.stack, int32(i.Arg), int32(i.Out))
+			case InstCapture, InstNop:
+				d.stack = append(d.stack, int32(i.Out))
+			case InstEmptyWidth:
+				if EmptyOp(i.Arg)&^cond == 0 {
+					d.stack = append(d.stack, int32(i.Out))
+				}
+			case InstMatch:
+				if d.set {
+					// Keep going to find every matching pattern.
+					matches = append(matches, i.Arg)
+					continue
+				}
+				matched = true
+				if !d.longest {
+					// Leftmost-first: cut off the lower-priority threads.
+					break Insts
+				}
+			case InstRune:
+				if i.matchRune(r, d.fold) && !d.added.contains(i.Out) {
+					d.added.add(i.Out)
+					next = append(next, int32(i.Out))
+				}
+			}
+		}
+	}
+	if len(next) > 0 && next[len(next)-1] == mark {
+		next = next[:len(next)-1]
+	}
+
+	flags := runeFlags(r) | s.flags&flagMatched
+	if matched {
+		flags |= flagMatch | flagMatched
+	}
+	if len(matches) > 0 {
+		flags |= flagMatch
+	}
+	ns := d.intern(next, flags, matches)
+	if 0 <= r && r < utf8.RuneSelf {
+		s.ascii[r] = ns
+	} else {
+		if s.next == nil {
+			s.next = make(map[rune]*dfaState)
+		}
+		s.next[r] = ns
+	}
+	return ns
+}
+
+// getDFA returns the pool of unanchored DFAs of re,
+// building it if necessary.
+func (re *Regexp) getDFA() *dfaPool {
+	re.dfaOnce.Do(func() {
+		p, longest := re.getProg(), re.longest
+		re.dfa = &dfaPool{new: func() *dfa {
+			return newDFA(p, p.Start, false, longest)
+		}}
+	})
+	return re.dfa
+}
@@ -0,0 +1,178 @@
+package regexp
+
+import (
+	stdregexp "regexp"
+	"strings"
+	"testing"
+
+	"github.com/tautastic/rex/utils"
+)
+
+var matchTests = []struct {
+	pattern, input string
+}{
+	{"abc", "xxabcxx"},
+	{"abc", "xxabxcx"},
+	{"a*a", "aaa"},
+	{"a|b", "cb"},
+	{"(ab|cd)+", "abcdab"},
+	{"^ab", "ab"},
+	{"^ab", "x\nab"},
+	{"^ab", "xab"},
+	{"ab$", "ab\nx"},
+	{"ab$", "abx"},
+	{"a\\b", "a b"},
+	{"a\\B", "a b"},
+	{"\\bfoo\\b", "a foo b"},
+	{"\\bfoo\\b", "afoob"},
+	{"[a-z]+=\\d+", "key=123"},
+	{"[a-z]+=\\d+", "key="},
+	{"a{2,3}", "aaaa"},
+	{"a{2,3}", "a"},
+	{"x(a|b)*y", "xababy"},
+	{"x(a|b)*y", "xabcy"},
+	{"(a*)*b", "aaab"},
+	{"(a*)+$", "aaab"},
+	{"[^a-c]+", "abc"},
+	{"\\d{3}-\\d{4}", "call 555-1234 now"},
+	{"(x?)*z", "xxz"},
+}
+
+func TestMatchString(t *testing.T) {
+	for _, test := range matchTests {
+		re := FromInfixExp(test.pattern)
+		want := stdregexp.MustCompile("(?m)" + test.pattern).MatchString(test.input)
+		if got := re.MatchString(test.input, false); got != want {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+		if got := re.doNFA(test.input, 0, 0, call{}) != nil; got != want {
+			t.Errorf("error: NFA %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+	}
+}
+
+func TestNFA(t *testing.T) {
+	for _, test := range matchTests {
+		re := FromInfixExp(test.pattern)
+		std := stdregexp.MustCompile("(?m)" + test.pattern)
+		want := std.FindStringSubmatchIndex(test.input)
+		got := re.doNFA(test.input, 0, re.NumSubexp(), call{})
+		if !intsEqual(got, want) {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+		re.Longest()
+		std.Longest()
+		want = std.FindStringIndex(test.input)
+		if got := re.doNFA(test.input, 0, 0, call{}); !intsEqual(got, want) {
+			t.Errorf("error: longest %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+		end, _, _ := re.getDFA().search(test.input, 0, false, call{})
+		if want != nil && end != want[1] {
+			t.Errorf("error: DFA longest %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, end, want[1])
+		}
+	}
+}
+
+func TestDFAFallback(t *testing.T) {
+	// (a|b)*a(a|b){12} needs exponentially many DFA states.
+	pattern := "(a|b)*a(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)c"
+	re := FromInfixExp(pattern)
+	var b strings.Builder
+	for x := uint32(1); b.Len() < 200000; x = x*1664525 + 1013904223 {
+		b.WriteByte("ab"[x>>31])
+	}
+	input := b.String() + "c"
+	if _, _, ok := re.getDFA().search(input, 0, false, call{}); ok {
+		t.Errorf("error: expected the DFA cache to thrash")
+	}
+	want := stdregexp.MustCompile(pattern).MatchString(input)
+	if got := re.MatchString(input, false); got != want {
+		t.Errorf("error:\ngot: %v\nwant: %v", got, want)
+	}
+}
+
+func intsEqual(a, b []int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return utils.Equal(a, b)
+}
+
+// TestDFAFold checks that searches ignoring case run on DFAs of their
+// own and leave the cache of the case-sensitive DFA alone.
+func TestDFAFold(t *testing.T) {
+	re := FromInfixExp("ab+")
+	dp := re.getDFA()
+	dp.search("xabbb", 0, false, call{})
+	d := dp.get(false)
+	n := len(d.states)
+	dp.put(d)
+	if _, matched, _ := dp.search("XABBB", 0, false, call{fold: true}); !matched {
+		t.Errorf("error: %q does not match %q ignoring case", "ab+", "XABBB")
+	}
+	if _, matched, _ := dp.search("XABBB", 0, false, call{}); matched {
+		t.Errorf("error: %q matches %q", "ab+", "XABBB")
+	}
+	if d := dp.get(false); len(d.states) < n || d.fold {
+		t.Errorf("error: case-sensitive DFA\ngot: %d states, fold %v\nwant: at least %d states, fold false", len(d.states), d.fold, n)
+	}
+}
+
+const benchPattern = "[a-z]+=\\d+;"
+
+func benchInput(size int) string {
+	var b strings.Builder
+	for b.Len() < size {
+		b.WriteString("key value, another one = x; ")
+	}
+	return b.String()[:size-5] + "x=42;"
+}
+
+func benchmarkDFA(b *testing.B, size int) {
+	b.StopTimer()
+	re := FromInfixExp(benchPattern)
+	input := benchInput(size)
+	b.SetBytes(int64(size))
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, matched, _ := re.getDFA().search(input, 0, true, call{}); !matched {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func benchmarkNFA(b *testing.B, size int) {
+	b.StopTimer()
+	re := FromInfixExp(benchPattern)
+	input := benchInput(size)
+	b.SetBytes(int64(size))
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if re.doNFA(input, 0, 0, call{}) == nil {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func benchmarkStdlib(b *testing.B, size int) {
+	b.StopTimer()
+	re := stdregexp.MustCompile(benchPattern)
+	input := benchInput(size)
+	b.SetBytes(int64(size))
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if !re.MatchString(input) {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func BenchmarkDFA1K(b *testing.B)     { benchmarkDFA(b, 1<<10) }
+func BenchmarkDFA32K(b *testing.B)    { benchmarkDFA(b, 32<<10) }
+func BenchmarkDFA1M(b *testing.B)     { benchmarkDFA(b, 1<<20) }
+func BenchmarkNFA1K(b *testing.B)     { benchmarkNFA(b, 1<<10) }
+func BenchmarkNFA32K(b *testing.B)    { benchmarkNFA(b, 32<<10) }
+func BenchmarkNFA1M(b *testing.B)     { benchmarkNFA(b, 1<<20) }
+func BenchmarkStdlib1K(b *testing.B)  { benchmarkStdlib(b, 1<<10) }
+func BenchmarkStdlib32K(b *testing.B) { benchmarkStdlib(b, 32<<10) }
+func BenchmarkStdlib1M(b *testing.B)  { benchmarkStdlib(b, 1<<20) }
@@ -0,0 +1,238 @@
+package regexp
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DOT returns the tree rooted at re in the Graphviz DOT language.
+func (re *Regexp) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph regexp {\n\tnode [shape=box];\n")
+	n := 0
+	var walk func(re *Regexp) int
+	walk = func(re *Regexp) int {
+		id := n
+		n++
+		fmt.Fprintf(&b, "\tn%d [label=%s];\n", id, strconv.Quote(nodeLabel(re)))
+		for _, sub := range re.Sub {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", id, walk(sub))
+		}
+		return id
+	}
+	walk(re)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// nodeLabel describes the node re without its subexpressions.
+func nodeLabel(re *Regexp) string {
+	switch re.Op {
+	case OpLiteral:
+		return "Literal " + strconv.Quote(string(re.Sym))
+	case OpCharClass:
+		var b strings.Builder
+		writeClass(&b, re.Sym)
+		return "CharClass " + b.String()
+	case OpRepeat:
+		if re.Max == -1 {
+			return fmt.Sprintf("Repeat {%d,}", re.Min)
+		}
+		return fmt.Sprintf("Repeat {%d,%d}", re.Min, re.Max)
+	case OpCapture:
+		return fmt.Sprintf("Capture %d", re.Cap)
+	}
+	return re.Op.String()
+}
+
+// DOT returns the instructions of p reachable from its start in the
+// Graphviz DOT language. Alternatives are drawn solid on the preferred
+// branch and dashed on the other.
+func (p *Prog) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph prog {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	fmt.Fprintf(&b, "\tstart [shape=point];\n\tstart -> i%d;\n", p.Start)
+	reached := make([]bool, len(p.Inst))
+	var reach func(pc uint32)
+	reach = func(pc uint32) {
+		if pc == 0 || reached[pc] {
+			return
+		}
+		reached[pc] = true
+		switch i := &p.Inst[pc]; i.Op {
+		case InstAlt:
+			reach(i.Out)
+			reach(i.Arg)
+		case InstCapture, InstEmptyWidth, InstNop, InstRune:
+			reach(i.Out)
+		}
+	}
+	reach(uint32(p.Start))
+	for pc := range p.Inst {
+		if !reached[pc] {
+			continue
+		}
+		i := &p.Inst[pc]
+		label := strconv.Itoa(pc)
+		shape := "circle"
+		switch i.Op {
+		case InstRune:
+			label += "\n" + runeLabel(i.Rune)
+			shape = "box"
+		case InstCapture:
+			label += fmt.Sprintf("\ncap %d", i.Arg)
+		case InstEmptyWidth:
+			label += "\n" + emptyLabel(EmptyOp(i.Arg))
+		case InstMatch:
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(&b, "\ti%d [label=%s, shape=%s];\n", pc, strconv.Quote(label), shape)
+		switch i.Op {
+		case InstAlt:
+			fmt.Fprintf(&b, "\ti%d -> i%d;\n\ti%d -> i%d [style=dashed];\n", pc, i.Out, pc, i.Arg)
+		case InstCapture, InstEmptyWidth, InstNop, InstRune:
+			fmt.Fprintf(&b, "\ti%d -> i%d;\n", pc, i.Out)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// runeLabel describes the runes consumed by an instruction.
+func runeLabel(rr RuneRange) string {
+	var b strings.Builder
+	if len(rr) == 1 {
+		writeRune(&b, rr[0], metaChars)
+	} else {
+		writeClass(&b, rr)
+	}
+	return b.String()
+}
+
+// emptyLabel describes the zero-width assertions in op.
+func emptyLabel(op EmptyOp) string {
+	var names []string
+	for _, e := range []struct {
+		op   EmptyOp
+		name string
+	}{
+		{EmptyLineStart, "^"},
+		{EmptyLineEnd, "$"},
+		{EmptyWordBoundary, `\b`},
+		{EmptyNoWordBoundary, `\B`},
+	} {
+		if op&e.op != 0 {
+			names = append(names, e.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// DFADOT returns the states of the lazy DFA of re built so far in the
+// Graphviz DOT language. The DFA is materialised by matching, so run
+// re on some input first, not ignoring case. States in which a match has ended before the
+// last rune are drawn with a double circle, and the end of the input
+// is labelled EOT.
+func (re *Regexp) DFADOT() string {
+	var b strings.Builder
+	b.WriteString("digraph dfa {\n\trankdir=LR;\n\tnode [shape=circle];\n\tstart [shape=point];\n")
+	// Draw the DFA that last finished a search not ignoring case.
+	dp := re.getDFA()
+	d := dp.get(false)
+	writeDFA(&b, d)
+	dp.put(d)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeDFA(b *strings.Builder, d *dfa) {
+	// Number the states in the order of their keys to keep
+	// the output stable.
+	keys := make([]string, 0, len(d.states))
+	for key := range d.states {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	ids := make(map[*dfaState]int, len(keys))
+	for _, key := range keys {
+		ids[d.states[key]] = len(ids)
+	}
+	for _, key := range keys {
+		s := d.states[key]
+		id := ids[s]
+		shape := "circle"
+		if s.flags&flagMatch != 0 {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(b, "\ts%d [shape=%s];\n", id, shape)
+		if d.isStart(s) {
+			fmt.Fprintf(b, "\tstart -> s%d;\n", id)
+		}
+		for _, e := range dfaEdges(s) {
+			if to, ok := ids[e.to]; ok {
+				fmt.Fprintf(b, "\ts%d -> s%d [label=%s];\n", id, to, strconv.Quote(e.label))
+			}
+		}
+	}
+}
+
+// isStart reports whether a search may begin in s.
+func (d *dfa) isStart(s *dfaState) bool {
+	if s.flags&(flagMatch|flagMatched) != 0 {
+		return false
+	}
+	if d.anchored {
+		return len(s.insts) == 1 && s.insts[0] == d.startPC
+	}
+	return len(s.insts) == 0
+}
+
+type dfaEdge struct {
+	to    *dfaState
+	label string
+}
+
+// dfaEdges returns the cached transitions out of s, merging
+// adjacent runes leading to the same state into ranges.
+func dfaEdges(s *dfaState) []dfaEdge {
+	var runes []rune
+	for r := rune(0); r < utf8.RuneSelf; r++ {
+		if s.ascii[r] != nil {
+			runes = append(runes, r)
+		}
+	}
+	for r := range s.next {
+		runes = append(runes, r)
+	}
+	slices.Sort(runes)
+	var edges []dfaEdge
+	labels := make(map[*dfaState]*RuneRange)
+	eot := make(map[*dfaState]bool)
+	var order []*dfaState
+	for _, r := range runes {
+		to := s.transition(r)
+		if _, ok := labels[to]; !ok {
+			labels[to] = new(RuneRange)
+			order = append(order, to)
+		}
+		if r == endOfText {
+			eot[to] = true
+			continue
+		}
+		*labels[to] = appendLiteral(*labels[to], r)
+	}
+	for _, to := range order {
+		var names []string
+		if rr := *labels[to]; len(rr) > 0 {
+			names = append(names, runeLabel(rr))
+		}
+		if eot[to] {
+			names = append(names, "EOT")
+		}
+		edges = append(edges, dfaEdge{to, strings.Join(names, " ")})
+	}
+	return edges
+}
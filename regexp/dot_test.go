@@ -0,0 +1,72 @@
+package regexp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	re := FromInfixExp("(a|bc)*[x-z]{2}$")
+	got := re.DOT()
+	for _, want := range []string{
+		"digraph regexp {",
+		`n0 [label="Concat"];`,
+		`[label="Repeat {0,}"];`,
+		`[label="Capture 1"];`,
+		`[label="Literal \"bc\""];`,
+		`[label="CharClass [x-z]"];`,
+		`[label="Repeat {2,2}"];`,
+		`[label="LineEnd"];`,
+		"n0 -> n1;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error: %q missing in\n%s", want, got)
+		}
+	}
+}
+
+func TestProgDOT(t *testing.T) {
+	re := FromInfixExp("a|b*")
+	p := re.Prog()
+	got := p.DOT()
+	for _, want := range []string{
+		"digraph prog {",
+		fmt.Sprintf("start -> i%d;", p.Start),
+		`\na", shape=box];`,
+		`[style=dashed];`,
+		"shape=doublecircle];",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error: %q missing in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "i0 ") || strings.Contains(got, "-> i0;") {
+		t.Errorf("error: unreachable fail instruction drawn in\n%s", got)
+	}
+}
+
+func TestDFADOT(t *testing.T) {
+	re := FromInfixExp("ab+")
+	if got, want := re.DFADOT(), "digraph dfa {\n\trankdir=LR;\n\tnode [shape=circle];\n\tstart [shape=point];\n}\n"; got != want {
+		t.Errorf("error: before matching\ngot: %q\nwant: %q", got, want)
+	}
+	if _, _, ok := re.getDFA().search("xabbb", 0, false, call{}); !ok {
+		t.Fatalf("error: DFA search failed")
+	}
+	got := re.DFADOT()
+	for _, want := range []string{
+		"start -> s",
+		`[label="a"];`,
+		`[label="b"];`,
+		`[label="EOT"];`,
+		"[shape=doublecircle];",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error: %q missing in\n%s", want, got)
+		}
+	}
+	if got != re.DFADOT() {
+		t.Errorf("error: output not stable")
+	}
+}
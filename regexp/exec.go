@@ -1,11 +1,22 @@
 package regexp
 
 import (
+	"strings"
 	"unicode/utf8"
 )
 
 const endOfText rune = -1
 
+// A call holds the state of a single call of a public method that is
+// shared by the engines it runs. The engines keep it with the rest of
+// their per-search state, so that calls running at the same time do
+// not interfere.
+type call struct {
+	fold   bool        // ignore case
+	budget *budget     // bounds the call, nil if it is unbounded
+	tracer func(Event) // receives the steps of the call, if traced
+}
+
 func step(str string, pos int) (rune, int) {
 	if -1 < pos && pos < len(str) {
 		c := str[pos]
@@ -17,132 +28,57 @@ func step(str string, pos int) (rune, int) {
 	return endOfText, 0
 }
 
-// doMatch reports whether str matches the regexp.
-func (re *Regexp) doMatch(str string) bool {
-	return re.doOnePass(str, 0, nil) != nil
+// doMatch reports whether str contains a match of the regexp.
+// It runs the lazy DFA and falls back to the NFA if the DFA gives up.
+func (re *Regexp) doMatch(str string, c call) bool {
+	if ac := re.getAhoCorasick(); ac != nil && !c.fold {
+		return ac.findIndex(str, 0, c) != nil
+	}
+	pf := re.getPrefilter()
+	if pf.complete && !c.fold {
+		return strings.Contains(str, pf.prefix)
+	}
+	if !pf.possible(str, 0, c.fold) {
+		return false
+	}
+	pos := pf.next(str, 0, c.fold)
+	if pos < 0 {
+		return false
+	}
+	if _, matched, ok := re.getDFA().search(str, pos, true, c); ok {
+		return matched
+	}
+	return re.doNFA(str, pos, 0, c) != nil
 }
 
-// doOnePass finds the leftmost match in the input, appends its position
-// to matches and returns matches.
-//
-// nil is returned if no matches are found and non-nil if matches are found.
-func (re *Regexp) doOnePass(str string, pos0 int, matches []int) []int {
-
-	didMatch := false
-	pos1 := pos0
-	rePos := 0
-
-	r0, r1, w0, w1 := endOfText, endOfText, 0, 0
-	r0, w0 = step(str, pos1)
-
-	for {
-		if len(re.Sub) <= rePos {
-			goto Return
+// doExecute finds the leftmost match in str at or after pos. It returns
+// the positions of the match followed by the positions of the first ncap
+// capture groups, or nil if there is no match. Searches without
+// captures run on the DFAs. Otherwise anchored unambiguous patterns
+// run on the one-pass machine, small searches on the backtracker and
+// everything else on the NFA.
+func (re *Regexp) doExecute(str string, pos int, ncap int, c call) []int {
+	if ac := re.getAhoCorasick(); ac != nil && ncap == 0 && !c.fold {
+		if matches := ac.findIndex(str, pos, c); matches != nil {
+			return matches[:2]
 		}
-		re1 := re.Sub[rePos]
-
-		switch re1.Op {
-		case OpAccept:
-			didMatch = true
-			goto Return
-
-		case OpWordBoundary:
-			prevRune, _ := step(str, pos1-1)
-			if isWordChar(prevRune) != isWordChar(r0) {
-				rePos++
-				continue
-			} else {
-				goto Return
-			}
-
-		case OpNotWordBoundary:
-			prevRune, _ := step(str, pos1-1)
-			if isWordChar(prevRune) == isWordChar(r0) {
-				rePos++
-				continue
-			} else {
-				goto Return
-			}
-
-		case OpLiteral, OpCharClass:
-			if !re1.matchRune(r0) {
-				goto Return
-			}
-			rePos++
-
-		case OpRepeat:
-			rMin, rMax, lastPos, mCount := re1.Min, re1.Max, pos1, 0
-			if rMax < 0 {
-				rMax = len(str)
-			}
-			re1.Sub = append(re1.Sub, &Regexp{Op: OpAccept})
-			for {
-				tmp := re1.doOnePass(str, lastPos, matches)
-				if rMax <= mCount || len(tmp) == 0 {
-					break
-				}
-				mCount++
-				lastPos = tmp[len(tmp)-1]
-			}
-			re1.Sub = re1.Sub[:len(re1.Sub)-1]
-			if rMin <= mCount && mCount <= rMax {
-				if lastPos != pos1 {
-					w0 = lastPos - pos1
-				}
-				rePos++
-			} else {
-				goto Return
-			}
-
-		case OpConcat:
-			if re1.Sub[len(re1.Sub)-1].Op != OpAccept {
-				re1.Sub = append(re1.Sub, &Regexp{Op: OpAccept})
-			}
-			tmp := re1.doOnePass(str, pos1, matches)
-			if tmp != nil {
-				w0 = tmp[1] - pos1
-				rePos++
-			} else {
-				goto Return
-			}
-
-		case OpAlternate:
-			alt0, alt1 := re1.Sub[0], re1.Sub[1]
-			if alt0.Sub[len(alt0.Sub)-1].Op != OpAccept {
-				alt0.Sub = append(alt0.Sub, &Regexp{Op: OpAccept})
-			}
-			tmp0 := alt0.doOnePass(str, pos1, matches)
-			if tmp0 != nil {
-				w0 = tmp0[1] - pos1
-				rePos++
-			} else {
-				if alt1.Sub[len(alt1.Sub)-1].Op != OpAccept {
-					alt1.Sub = append(alt1.Sub, &Regexp{Op: OpAccept})
-				}
-				tmp1 := alt1.doOnePass(str, pos1, matches)
-				if tmp1 != nil {
-					w0 = tmp1[1] - pos1
-					rePos++
-				} else {
-					goto Return
-				}
-			}
-		}
-
-		if w0 == 0 {
-			break
+		return nil
+	}
+	if pos = re.getPrefilter().next(str, pos, c.fold); pos < 0 {
+		return nil
+	}
+	if ncap == 0 {
+		if matches, ok := re.doDFA(str, pos, c); ok {
+			return matches
 		}
-		if r0 != endOfText {
-			r1, w1 = step(str, pos1+w0)
+	}
+	if re.getOnePass() != nil {
+		if matches, ok := re.doOnePass(str, pos, ncap, c); ok {
+			return matches
 		}
-		pos1 += w0
-		r0, w0 = r1, w1
 	}
-
-Return:
-	if didMatch {
-		matches = append(matches, pos0, pos1)
-		return matches
+	if re.shouldBacktrack(len(str) - pos) {
+		return re.doBacktrack(str, pos, ncap, c)
 	}
-	return nil
+	return re.doNFA(str, pos, ncap, c)
 }
@@ -0,0 +1,265 @@
+package regexp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tautastic/rex/syntax"
+	"github.com/tautastic/rex/utils"
+)
+
+// perlClassNames describes the classes in PerlClass.
+var perlClassNames = map[uint8]string{
+	'd': "digit",
+	'D': "non-digit",
+	's': "whitespace character",
+	'S': "non-whitespace character",
+	'w': "word character",
+	'W': "non-word character",
+}
+
+// uniClassNames describes the categories in UniClass.
+var uniClassNames = map[string]string{
+	"C":  "other character",
+	"Cc": "control character",
+	"Cf": "format character",
+	"Co": "private use character",
+	"Cs": "surrogate",
+	"L":  "letter",
+	"Ll": "lowercase letter",
+	"Lm": "modifier letter",
+	"Lo": "other letter",
+	"Lt": "titlecase letter",
+	"Lu": "uppercase letter",
+	"M":  "mark",
+	"Mc": "spacing combining mark",
+	"Me": "enclosing mark",
+	"Mn": "nonspacing mark",
+	"N":  "number",
+	"Nd": "decimal digit",
+	"Nl": "letter number",
+	"No": "other number",
+	"P":  "punctuation character",
+	"Pc": "connector punctuation",
+	"Pd": "dash punctuation",
+	"Pe": "closing punctuation",
+	"Po": "other punctuation",
+	"Ps": "opening punctuation",
+	"S":  "symbol",
+	"Sc": "currency symbol",
+	"Sk": "modifier symbol",
+	"Sm": "math symbol",
+	"So": "other symbol",
+	"Z":  "separator",
+	"Zl": "line separator",
+	"Zp": "paragraph separator",
+	"Zs": "space separator",
+}
+
+// controlNames describes the control escapes.
+var controlNames = map[string]string{
+	"f": "form feed",
+	"n": "newline",
+	"r": "carriage return",
+	"t": "tab",
+	"v": "vertical tab",
+}
+
+// Explain describes the pattern expr in plain English, one step per
+// line, indenting the contents of groups, alternatives and repetitions.
+func Explain(expr string) (string, error) {
+	if _, err := Parse(expr); err != nil {
+		return "", err
+	}
+	e := &explainer{}
+	e.disjunction(syntax.ToSyntaxTree(expr), 0)
+	return e.b.String(), nil
+}
+
+// An explainer holds the state of a single explanation.
+type explainer struct {
+	b      strings.Builder
+	numCap int
+}
+
+func (e *explainer) line(depth int, text string) {
+	e.b.WriteString(strings.Repeat("  ", depth))
+	e.b.WriteString(text)
+	e.b.WriteByte('\n')
+}
+
+// disjunction explains the alternatives of node.
+func (e *explainer) disjunction(node *syntax.Node, depth int) {
+	var alts []*syntax.Node
+	for ; len(node.Sub) == 2; node = node.Sub[1] {
+		alts = append(alts, node.Sub[0])
+	}
+	alts = append(alts, node.Sub[0])
+	if len(alts) == 1 {
+		e.term(alts[0], depth)
+		return
+	}
+	for j, alt := range alts {
+		if j == 0 {
+			e.line(depth, "either:")
+		} else {
+			e.line(depth, "or:")
+		}
+		e.term(alt, depth+1)
+	}
+}
+
+// term explains the factors of node in sequence, merging runs of
+// plain literals into strings.
+func (e *explainer) term(node *syntax.Node, depth int) {
+	var factors []*syntax.Node
+	for ; len(node.Sub) == 2; node = node.Sub[1] {
+		factors = append(factors, node.Sub[0])
+	}
+	factors = append(factors, node.Sub[0])
+	for j := 0; j < len(factors); {
+		k := j
+		var lit strings.Builder
+		for ; k < len(factors); k++ {
+			f := factors[k]
+			if len(f.Sub) != 1 || f.Sub[0].Kind != syntax.KindAtom || f.Sub[0].Sub[0].Kind != syntax.KindLiteral {
+				break
+			}
+			lit.WriteString(f.Sub[0].Sub[0].Value)
+		}
+		if k-j > 1 {
+			e.line(depth, "the string "+strconv.Quote(lit.String()))
+			j = k
+			continue
+		}
+		e.factor(factors[j], depth)
+		j++
+	}
+}
+
+// factor explains an assertion or a possibly quantified atom.
+func (e *explainer) factor(node *syntax.Node, depth int) {
+	if node.Sub[0].Kind == syntax.KindAssertion {
+		e.line(depth, assertionName(node.Sub[0].Value))
+		return
+	}
+	if len(node.Sub) == 1 {
+		e.atom(node.Sub[0], depth, "")
+		return
+	}
+	e.atom(node.Sub[0], depth, quantifierName(node.Sub[1]))
+}
+
+// atom explains node, prefixed by lead if it is not empty.
+func (e *explainer) atom(node *syntax.Node, depth int, lead string) {
+	sub := node.Sub[0]
+	if sub.Kind != syntax.KindDisjunction {
+		if lead != "" {
+			lead += ": "
+		}
+		e.line(depth, lead+atomName(sub))
+		return
+	}
+	e.numCap++
+	if lead != "" {
+		e.line(depth, lead+":")
+		depth++
+	}
+	e.line(depth, fmt.Sprintf("group %d:", e.numCap))
+	e.disjunction(sub, depth+1)
+}
+
+func assertionName(value string) string {
+	switch value {
+	case "^":
+		return "start of line"
+	case "$":
+		return "end of line"
+	case "b":
+		return "word boundary"
+	}
+	return "not a word boundary"
+}
+
+func quantifierName(node *syntax.Node) string {
+	lo, hi := node.Sub[0].Value, node.Sub[1].Value
+	switch {
+	case lo == "0" && hi == "-1":
+		return "zero or more of"
+	case lo == "1" && hi == "-1":
+		return "one or more of"
+	case lo == "0" && hi == "1":
+		return "optionally"
+	case hi == "-1":
+		return lo + " or more of"
+	case lo == hi:
+		return "exactly " + lo + " of"
+	}
+	return "between " + lo + " and " + hi + " of"
+}
+
+// atomName describes an atom other than a group.
+func atomName(node *syntax.Node) string {
+	switch node.Kind {
+	case syntax.KindDot:
+		return "any character"
+	case syntax.KindClass:
+		return bracketName(node)
+	}
+	return classAtomName(node)
+}
+
+// classAtomName describes a single character or class escape.
+func classAtomName(node *syntax.Node) string {
+	switch node.Kind {
+	case syntax.KindLiteral:
+		return "the character " + strconv.Quote(node.Value)
+	case syntax.KindControl:
+		return controlNames[node.Value]
+	case syntax.KindHexSeq:
+		r := hexSeqToRune(node.Value)
+		return fmt.Sprintf("the character U+%04X %q", r, r)
+	case syntax.KindPerl:
+		return perlClassNames[node.Value[0]]
+	case syntax.KindUniSeq:
+		name, negated := strings.CutPrefix(node.Value, "^")
+		desc, ok := uniClassNames[name]
+		if !ok {
+			desc = "character of category " + name
+		}
+		if negated {
+			return "any character that is not a Unicode " + desc
+		}
+		return "Unicode " + desc
+	case syntax.KindClassRange:
+		return classItemName(node.Sub[0]) + " to " + classItemName(node.Sub[1])
+	}
+	panic(utils.ErrUnexpectedSymbol)
+}
+
+// bracketName describes a bracketed character class.
+func bracketName(node *syntax.Node) string {
+	lead := "any of"
+	var items []string
+	for _, sub := range node.Sub {
+		if sub.Kind == syntax.KindNegation {
+			lead = "any character except"
+			continue
+		}
+		items = append(items, classItemName(sub))
+	}
+	if len(items) == 0 {
+		if lead == "any of" {
+			return "nothing"
+		}
+		return "any character"
+	}
+	return lead + ": " + strings.Join(items, ", ")
+}
+
+// classItemName describes an element of a bracketed class, leaving
+// out the article of single characters.
+func classItemName(node *syntax.Node) string {
+	return strings.TrimPrefix(classAtomName(node), "the character ")
+}
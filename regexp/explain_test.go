@@ -0,0 +1,40 @@
+package regexp
+
+import (
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	for _, test := range []struct {
+		pattern, want string
+	}{
+		{"abc", "the string \"abc\"\n"},
+		{"a", "the character \"a\"\n"},
+		{"\\p{Lu}+", "one or more of: Unicode uppercase letter\n"},
+		{"\\P{Nd}?", "optionally: any character that is not a Unicode decimal digit\n"},
+		{"^\\d{3}-\\w{2,}$", "start of line\nexactly 3 of: digit\nthe character \"-\"\n2 or more of: word character\nend of line\n"},
+		{"x{1,4}\\B.", "between 1 and 4 of: the character \"x\"\nnot a word boundary\nany character\n"},
+		{"[^a-z\\d\\t]*", "zero or more of: any character except: \"a\" to \"z\", digit, tab\n"},
+		{"[\\x{41}_]\\.", "any of: U+0041 'A', \"_\"\nthe character \".\"\n"},
+		{"a|bc|\\s", "either:\n  the character \"a\"\nor:\n  the string \"bc\"\nor:\n  whitespace character\n"},
+		{"(a(b))+c", "one or more of:\n  group 1:\n    the character \"a\"\n    group 2:\n      the character \"b\"\nthe character \"c\"\n"},
+		{"(x|y)\\b", "group 1:\n  either:\n    the character \"x\"\n  or:\n    the character \"y\"\nword boundary\n"},
+	} {
+		got, err := Explain(test.pattern)
+		if err != nil {
+			t.Errorf("error: %q: %v", test.pattern, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("error: %q\ngot:\n%s\nwant:\n%s", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestExplainError(t *testing.T) {
+	for _, pattern := range []string{"", "(a", "a{2,1}", "[a-\\d]"} {
+		if _, err := Explain(pattern); err == nil {
+			t.Errorf("error: %q explained", pattern)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package regexp
+
+import (
+	"math/rand"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// defaultMaxRepeat is the default number of repetitions
+	// a generator adds to the minimum of an unbounded repeat.
+	defaultMaxRepeat = 8
+	// maxGenerateTries bounds the attempts at finding a string
+	// that passes the assertions of a pattern, or a near miss.
+	maxGenerateTries = 100
+)
+
+// A Generator produces random strings from a Regexp, for use in
+// property-based tests. The strings depend only on the seed given to
+// NewGenerator, so a failing test can be reproduced.
+type Generator struct {
+	// MaxRepeat bounds the number of repetitions beyond Min
+	// of repeats without a maximum.
+	MaxRepeat int
+
+	re  *Regexp
+	rnd *rand.Rand
+}
+
+// NewGenerator returns a generator of strings for re seeded with seed.
+func NewGenerator(re *Regexp, seed int64) *Generator {
+	return &Generator{
+		MaxRepeat: defaultMaxRepeat,
+		re:        re,
+		rnd:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Generate returns a random string matched by re. It walks the tree
+// of re, choosing branches, numbers of repetitions and runes of classes
+// at random. Since assertions are only checked on the result, Generate
+// reports false if no string passing them was found.
+func (g *Generator) Generate() (string, bool) {
+	for range maxGenerateTries {
+		var b []rune
+		b = g.generate(g.re, b)
+		if s := string(b); g.re.MatchString(s, false) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// NearMiss returns a random string not matched by re that is a single
+// deletion, insertion or substitution of a rune away from a string
+// returned by Generate. It reports false if none was found, as for
+// patterns like a* that match every string.
+func (g *Generator) NearMiss() (string, bool) {
+	for range maxGenerateTries {
+		s, ok := g.Generate()
+		if !ok {
+			return "", false
+		}
+		rs := []rune(s)
+		i := g.rnd.Intn(len(rs) + 1)
+		switch op := g.rnd.Intn(3); {
+		case op == 0 && i < len(rs):
+			rs = append(rs[:i], rs[i+1:]...)
+		case op == 1 && i < len(rs):
+			rs[i] = g.mutate(rs[i])
+		default:
+			rs = append(rs[:i], append([]rune{g.mutate(-1)}, rs[i:]...)...)
+		}
+		if s := string(rs); !g.re.MatchString(s, false) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// mutate returns a random rune other than r, which is a neighbour
+// of r or a printable ASCII character.
+func (g *Generator) mutate(r rune) rune {
+	for {
+		c := ' ' + rune(g.rnd.Intn('~'-' '+1))
+		if r >= 0 && g.rnd.Intn(2) == 0 {
+			c = r + rune(g.rnd.Intn(3)) - 1
+		}
+		if c != r && utf8.ValidRune(c) {
+			return c
+		}
+	}
+}
+
+// generate appends a random string matched by re to b.
+func (g *Generator) generate(re *Regexp, b []rune) []rune {
+	switch re.Op {
+	case OpLiteral:
+		return append(b, re.Sym...)
+	case OpCharClass:
+		if r, ok := g.pick(re.Sym); ok {
+			return append(b, r)
+		}
+	case OpRepeat:
+		hi := re.Max
+		if hi == -1 {
+			hi = re.Min + g.MaxRepeat
+		}
+		n := re.Min + g.rnd.Intn(hi-re.Min+1)
+		for range n {
+			b = g.generate(re.Sub[0], b)
+		}
+	case OpConcat:
+		for _, sub := range re.Sub {
+			b = g.generate(sub, b)
+		}
+	case OpAlternate:
+		return g.generate(re.Sub[g.rnd.Intn(len(re.Sub))], b)
+	case OpCapture:
+		return g.generate(re.Sub[0], b)
+	}
+	return b
+}
+
+// pick returns a random rune of rr. It prefers printable ASCII
+// characters, which make failing examples easier to read, and never
+// returns surrogates. It reports false if rr has no valid rune.
+func (g *Generator) pick(rr RuneRange) (rune, bool) {
+	if g.rnd.Intn(4) != 0 {
+		if r, ok := g.pickIn(rr, ' ', '~'); ok {
+			return r, true
+		}
+	}
+	return g.pickIn(rr, 0, utf8.MaxRune)
+}
+
+// pickIn returns a random rune of rr between lo and hi.
+func (g *Generator) pickIn(rr RuneRange, lo, hi rune) (rune, bool) {
+	var total int64
+	for i := 0; i < len(rr); i += 2 {
+		if l, h := max(rr[i], lo), min(rr[i+1], hi); l <= h {
+			total += int64(h - l + 1)
+		}
+	}
+	for range maxGenerateTries {
+		if total == 0 {
+			break
+		}
+		n := g.rnd.Int63n(total)
+		for i := 0; i < len(rr); i += 2 {
+			l, h := max(rr[i], lo), min(rr[i+1], hi)
+			if l > h {
+				continue
+			}
+			if size := int64(h - l + 1); n >= size {
+				n -= size
+				continue
+			}
+			if r := l + rune(n); utf8.ValidRune(r) {
+				return r, true
+			}
+			break
+		}
+	}
+	return 0, false
+}
+
+// ShortestMatches returns up to n of the shortest strings matched by
+// re, counting runes, in lexicographic order. Assertions are only
+// checked on the results, so fewer than n strings may be returned
+// even if more exist.
+func (re *Regexp) ShortestMatches(n int) []string {
+	var out []string
+	for _, s := range shortestStrings(re, n) {
+		if re.MatchString(s, false) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// shortestStrings returns up to n of the shortest strings matched by
+// re, ignoring assertions, in lexicographic order. All of them have
+// the same number of runes. It returns nil if re matches nothing.
+func shortestStrings(re *Regexp, n int) []string {
+	switch re.Op {
+	case OpLiteral:
+		return []string{string(re.Sym)}
+	case OpCharClass:
+		var out []string
+		for i := 0; i < len(re.Sym) && len(out) < n; i += 2 {
+			for r := re.Sym[i]; r <= re.Sym[i+1] && len(out) < n; r++ {
+				if utf8.ValidRune(r) {
+					out = append(out, string(r))
+				}
+			}
+		}
+		return out
+	case OpRepeat:
+		out := []string{""}
+		if re.Min == 0 {
+			return out
+		}
+		sub := shortestStrings(re.Sub[0], n)
+		for range re.Min {
+			out = product(out, sub, n)
+		}
+		return out
+	case OpConcat:
+		out := []string{""}
+		for _, sub := range re.Sub {
+			out = product(out, shortestStrings(sub, n), n)
+		}
+		return out
+	case OpAlternate:
+		var out []string
+		length := -1
+		for _, sub := range re.Sub {
+			s := shortestStrings(sub, n)
+			if len(s) == 0 {
+				continue
+			}
+			switch l := utf8.RuneCountInString(s[0]); {
+			case length == -1 || l < length:
+				out, length = s, l
+			case l == length:
+				out = append(out, s...)
+			}
+		}
+		sort.Strings(out)
+		out = dedup(out)
+		return out[:min(n, len(out))]
+	case OpCapture:
+		return shortestStrings(re.Sub[0], n)
+	}
+	return []string{""}
+}
+
+// product returns up to n concatenations of a string of a with a
+// string of b, in lexicographic order if a and b are sorted and the
+// strings of each have the same number of runes.
+func product(a, b []string, n int) []string {
+	var out []string
+	for _, x := range a {
+		for _, y := range b {
+			if len(out) == n {
+				return out
+			}
+			out = append(out, x+y)
+		}
+	}
+	return out
+}
+
+// dedup removes adjacent duplicates from the sorted slice s.
+func dedup(s []string) []string {
+	w := 0
+	for i, x := range s {
+		if i == 0 || x != s[w-1] {
+			s[w] = x
+			w++
+		}
+	}
+	return s[:w]
+}
@@ -0,0 +1,89 @@
+package regexp
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+var generateTests = []string{
+	"abc",
+	"ab|cd",
+	"[a-c]{2}x?",
+	`\d+-\w*`,
+	"(x|yz)+q",
+	"^a.b$",
+	`\bfoo\b`,
+	`[^a-z]{3,5}`,
+	`^\p{Lu}+$`,
+}
+
+func TestGenerate(t *testing.T) {
+	for _, expr := range generateTests {
+		re := FromInfixExp(expr)
+		g := NewGenerator(&re, 1)
+		for range 50 {
+			s, ok := g.Generate()
+			if !ok || !utf8.ValidString(s) || !re.MatchString(s, false) {
+				t.Errorf("error: Generate for %q\ngot: %q %v\nwant: a match", expr, s, ok)
+			}
+			s, ok = g.NearMiss()
+			if !ok || re.MatchString(s, false) {
+				t.Errorf("error: NearMiss for %q\ngot: %q %v\nwant: a non-match", expr, s, ok)
+			}
+		}
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	re := FromInfixExp(`[a-z]+\d*`)
+	g1, g2 := NewGenerator(&re, 42), NewGenerator(&re, 42)
+	for range 10 {
+		s1, _ := g1.Generate()
+		s2, _ := g2.Generate()
+		if s1 != s2 {
+			t.Errorf("error: same seed\ngot: %q\nwant: %q", s2, s1)
+		}
+	}
+}
+
+func TestGenerateMaxRepeat(t *testing.T) {
+	re := FromInfixExp("a{2,}")
+	g := NewGenerator(&re, 1)
+	g.MaxRepeat = 3
+	for range 50 {
+		if s, _ := g.Generate(); len(s) < 2 || len(s) > 5 {
+			t.Errorf("error: length of %q\ngot: %d\nwant: 2 to 5", s, len(s))
+		}
+	}
+}
+
+func TestNearMissNone(t *testing.T) {
+	re := FromInfixExp("a*")
+	if s, ok := NewGenerator(&re, 1).NearMiss(); ok {
+		t.Errorf("error: NearMiss for a*\ngot: %q\nwant: none", s)
+	}
+}
+
+func TestShortestMatches(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		n    int
+		want []string
+	}{
+		{"abc", 5, []string{"abc"}},
+		{"cd|ab|xyz", 5, []string{"ab", "cd"}},
+		{"[a-c]{2}x?", 5, []string{"aa", "ab", "ac", "ba", "bb"}},
+		{"(x|yz)+q", 5, []string{"xq"}},
+		{"a*b?", 5, []string{""}},
+		{"[0-9]-[ab]", 3, []string{"0-a", "0-b", "1-a"}},
+		{"b|a|b", 5, []string{"a", "b"}},
+		{"^a$|b", 5, []string{"a", "b"}},
+		{"a^b", 5, nil},
+	} {
+		re := FromInfixExp(test.expr)
+		if got := re.ShortestMatches(test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("error: %q\ngot: %q\nwant: %q", test.expr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package regexp
+
+import "iter"
+
+// All returns an iterator over the successive non-overlapping matches
+// of re in str. Matches are found lazily as the iteration proceeds.
+func (re *Regexp) All(str string, i bool) iter.Seq[Match] {
+	return func(yield func(Match) bool) {
+		re.allMatches(str, len(str)+1, re.NumSubexp(), call{fold: i}, func(match []int) bool {
+			return yield(Match{str: str, index: match})
+		})
+	}
+}
+
+// AllIndex returns an iterator over the byte offsets of the successive
+// non-overlapping matches of re in str.
+func (re *Regexp) AllIndex(str string, i bool) iter.Seq[[]int] {
+	return func(yield func([]int) bool) {
+		re.allMatches(str, len(str)+1, 0, call{fold: i}, func(match []int) bool {
+			return yield(match[0:2])
+		})
+	}
+}
+
+// AllSubmatch returns an iterator over the successive non-overlapping
+// matches of re in str. Each value holds the text of the match followed
+// by the text of every capture group.
+func (re *Regexp) AllSubmatch(str string, i bool) iter.Seq[[]string] {
+	return func(yield func([]string) bool) {
+		for m := range re.All(str, i) {
+			if !yield(m.Groups()) {
+				return
+			}
+		}
+	}
+}
+
+// SplitSeq returns an iterator over the substrings of str
+// separated by the matches of re.
+func (re *Regexp) SplitSeq(str string, i bool) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		beg, end := 0, 0
+		stopped := false
+		re.allMatches(str, len(str)+1, 0, call{fold: i}, func(match []int) bool {
+			end = match[0]
+			if match[1] != 0 {
+				if !yield(str[beg:end]) {
+					stopped = true
+					return false
+				}
+			}
+			beg = match[1]
+			return true
+		})
+		if !stopped && end != len(str) {
+			yield(str[beg:])
+		}
+	}
+}
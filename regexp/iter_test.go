@@ -0,0 +1,67 @@
+package regexp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	re := FromInfixExp("(\\w+)=(\\d+)?")
+	var got []string
+	for m := range re.All("a=1, bc=, d=42", false) {
+		got = append(got, strings.Join(m.Groups(), "|"))
+		if s, e := m.GroupIndex(0); s != m.Start() || e != m.End() {
+			t.Errorf("error:\ngot: %v, %v\nwant: %v, %v", s, e, m.Start(), m.End())
+		}
+	}
+	if want := "a=1|a|1,bc=|bc|,d=42|d|42"; strings.Join(got, ",") != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", strings.Join(got, ","), want)
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	re := FromInfixExp("\\d")
+	var got [][]int
+	for loc := range re.AllIndex("1a2b3c4", false) {
+		got = append(got, loc)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0][0] != 0 || got[1][0] != 2 {
+		t.Errorf("error:\ngot: %v\nwant: [[0 1] [2 3]]", got)
+	}
+}
+
+func TestAllSubmatch(t *testing.T) {
+	re := FromInfixExp("(a(b)?)c")
+	var got []string
+	for groups := range re.AllSubmatch("abc ac", false) {
+		got = append(got, strings.Join(groups, "|"))
+	}
+	if want := "abc|ab|b,ac|a|"; strings.Join(got, ",") != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", strings.Join(got, ","), want)
+	}
+}
+
+func TestSplitSeq(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+		want           []string
+	}{
+		{",", "a,b,c", []string{"a", "b", "c"}},
+		{"\\s+", "one  two\tthree", []string{"one", "two", "three"}},
+		{"x", "axbx", []string{"a", "b", ""}},
+		{"z", "abc", []string{"abc"}},
+	} {
+		re := FromInfixExp(test.pattern)
+		var got []string
+		for s := range re.SplitSeq(test.input, false) {
+			got = append(got, s)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("error: %q on %q\ngot: %q\nwant: %q",
+				test.pattern, test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package regexp
+
+// Limits bounds the resources a pattern may claim, so that patterns
+// from untrusted sources can be compiled safely. A zero field means
+// no limit.
+type Limits struct {
+	MaxLen     int // longest pattern in bytes
+	MaxRepeat  int // largest count in a repetition {n,m}
+	MaxDepth   int // deepest nesting of groups and repetitions
+	MaxProg    int // most instructions in the compiled program
+	MaxClasses int // most character classes, including \d, \p{..} and .
+}
+
+// DefaultLimits are the limits applied by Compile, CompilePOSIX
+// and FromInfixExp.
+var DefaultLimits = Limits{
+	MaxLen:     1 << 18,
+	MaxRepeat:  1000,
+	MaxDepth:   1000,
+	MaxProg:    1 << 17,
+	MaxClasses: 10000,
+}
@@ -0,0 +1,274 @@
+package regexp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxLiterals bounds the size of a literal set.
+	maxLiterals = 16
+	// maxClassLiterals is the largest character class
+	// that is expanded into a set of literals.
+	maxClassLiterals = 4
+)
+
+// literals describes the strings that a node is known to match.
+// A nil set means nothing is known.
+type literals struct {
+	exact  []string // the node matches exactly one of these strings
+	prefix []string // every match starts with one of these strings
+	suffix []string // every match ends with one of these strings
+	inner  []string // every match contains one of these strings
+}
+
+// literalSets walks the syntax tree and extracts the literal
+// strings every match of re must start with, end with or contain.
+func literalSets(re *Regexp) literals {
+	switch re.Op {
+	case OpLiteral:
+		return exactLiterals([]string{string(re.Sym)})
+	case OpCharClass:
+		var lits []string
+		for i := 0; i < len(re.Sym); i += 2 {
+			for r := re.Sym[i]; r <= re.Sym[i+1]; r++ {
+				if len(lits) == maxClassLiterals {
+					return literals{}
+				}
+				lits = append(lits, string(r))
+			}
+		}
+		return exactLiterals(lits)
+	case OpLineStart, OpLineEnd, OpWordBoundary, OpNotWordBoundary, OpAccept:
+		return exactLiterals([]string{""})
+	case OpCapture:
+		return literalSets(re.Sub[0])
+	case OpConcat:
+		lits := exactLiterals([]string{""})
+		for _, sub := range re.Sub {
+			lits = concatLiterals(lits, literalSets(sub))
+		}
+		return lits
+	case OpAlternate:
+		lits := literalSets(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			lits = alternateLiterals(lits, literalSets(sub))
+		}
+		return lits
+	case OpRepeat:
+		if re.Min == 0 {
+			return literals{}
+		}
+		sub := literalSets(re.Sub[0])
+		if re.Min == 1 && re.Max == 1 {
+			return sub
+		}
+		return literals{prefix: sub.prefix, suffix: sub.suffix, inner: sub.inner}
+	}
+	return literals{}
+}
+
+func exactLiterals(lits []string) literals {
+	return literals{exact: lits, prefix: lits, suffix: lits, inner: lits}
+}
+
+// cross returns every concatenation of a string in a with a string in b,
+// or nil if there would be too many.
+func cross(a, b []string) []string {
+	if a == nil || b == nil || len(a)*len(b) > maxLiterals {
+		return nil
+	}
+	var lits []string
+	for _, x := range a {
+		for _, y := range b {
+			lits = append(lits, x+y)
+		}
+	}
+	return dedupLiterals(lits)
+}
+
+func concatLiterals(a, b literals) literals {
+	var lits literals
+	lits.exact = cross(a.exact, b.exact)
+	lits.prefix = a.prefix
+	if a.exact != nil {
+		if p := cross(a.exact, b.prefix); p != nil {
+			lits.prefix = p
+		}
+	}
+	lits.suffix = b.suffix
+	if b.exact != nil {
+		if s := cross(a.suffix, b.exact); s != nil {
+			lits.suffix = s
+		}
+	}
+	lits.inner = bestLiterals(a.inner, b.inner, cross(a.suffix, b.prefix),
+		lits.exact, lits.prefix, lits.suffix)
+	return lits
+}
+
+func alternateLiterals(a, b literals) literals {
+	union := func(x, y []string) []string {
+		if x == nil || y == nil || len(x)+len(y) > maxLiterals {
+			return nil
+		}
+		return dedupLiterals(append(append([]string(nil), x...), y...))
+	}
+	return literals{
+		exact:  union(a.exact, b.exact),
+		prefix: union(a.prefix, b.prefix),
+		suffix: union(a.suffix, b.suffix),
+		inner:  union(a.inner, b.inner),
+	}
+}
+
+func dedupLiterals(lits []string) []string {
+	var out []string
+	for _, lit := range lits {
+		dup := false
+		for _, o := range out {
+			if o == lit {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, lit)
+		}
+	}
+	return out
+}
+
+// minLen returns the length of the shortest string in lits,
+// or 0 if lits is nil.
+func minLen(lits []string) int {
+	if lits == nil {
+		return 0
+	}
+	n := len(lits[0])
+	for _, lit := range lits[1:] {
+		n = min(n, len(lit))
+	}
+	return n
+}
+
+// bestLiterals returns the set whose shortest literal is the longest.
+func bestLiterals(sets ...[]string) []string {
+	var best []string
+	for _, lits := range sets {
+		if minLen(lits) > minLen(best) {
+			best = lits
+		}
+	}
+	return best
+}
+
+// LiteralPrefix returns a literal string that must begin any match
+// of the regular expression re. It returns the boolean true if the
+// literal string comprises the entire regular expression.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	pf := re.getPrefilter()
+	return pf.prefix, pf.complete
+}
+
+// isLiteral reports whether re consists of literal runes only.
+func isLiteral(re *Regexp) bool {
+	switch re.Op {
+	case OpLiteral, OpAccept:
+		return true
+	case OpConcat:
+		for _, sub := range re.Sub {
+			if !isLiteral(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// A prefilter skips input that cannot contain a match
+// before any of the engines is run.
+type prefilter struct {
+	prefix   string     // every match starts with prefix
+	first    string     // every match starts with one of these runes
+	required [][]string // every match contains a string from each set
+	complete bool       // prefix is the entire regular expression
+}
+
+func newPrefilter(re *Regexp) *prefilter {
+	lits := literalSets(re)
+	pf := &prefilter{}
+	switch {
+	case len(lits.prefix) == 1:
+		pf.prefix = lits.prefix[0]
+		pf.complete = isLiteral(re)
+	case minLen(lits.prefix) > 0:
+		for _, lit := range lits.prefix {
+			r, _ := utf8.DecodeRuneInString(lit)
+			if !strings.ContainsRune(pf.first, r) {
+				pf.first += string(r)
+			}
+		}
+	}
+	for _, lits := range [][]string{lits.suffix, lits.inner} {
+		if minLen(lits) > 0 {
+			pf.required = append(pf.required, lits)
+		}
+	}
+	return pf
+}
+
+// getPrefilter returns the prefilter of re, building it if necessary.
+func (re *Regexp) getPrefilter() *prefilter {
+	re.prefilterOnce.Do(func() {
+		re.prefilter = newPrefilter(re)
+	})
+	return re.prefilter
+}
+
+// possible reports whether str[pos:] can contain a match,
+// ignoring case if fold is set.
+func (pf *prefilter) possible(str string, pos int, fold bool) bool {
+	if fold {
+		return true
+	}
+	for _, lits := range pf.required {
+		found := false
+		for _, lit := range lits {
+			if strings.Contains(str[pos:], lit) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the first position at or after pos where a match can
+// start, or -1 if there is none, ignoring case if fold is set.
+func (pf *prefilter) next(str string, pos int, fold bool) int {
+	if fold || pos >= len(str) {
+		return pos
+	}
+	var i int
+	switch {
+	case len(pf.prefix) == 1:
+		i = strings.IndexByte(str[pos:], pf.prefix[0])
+	case pf.prefix != "":
+		i = strings.Index(str[pos:], pf.prefix)
+	case len(pf.first) == 1:
+		i = strings.IndexByte(str[pos:], pf.first[0])
+	case pf.first != "":
+		i = strings.IndexAny(str[pos:], pf.first)
+	default:
+		return pos
+	}
+	if i < 0 {
+		return -1
+	}
+	return pos + i
+}
@@ -0,0 +1,95 @@
+package regexp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLiteralSets(t *testing.T) {
+	for _, test := range []struct {
+		pattern               string
+		prefix, suffix, inner string
+	}{
+		{"ERROR: \\w+", "ERROR: ", "", "ERROR: "},
+		{"user_id=\\d+", "user_id=", "", "user_id="},
+		{"\\d+[.]log$", "", ".log", ".log"},
+		{"(foo|bar)\\d+", "foo,bar", "", "foo,bar"},
+		{"\\w+@example[.]com", "", "@example.com", "@example.com"},
+		{"[ab]c", "ac,bc", "ac,bc", "ac,bc"},
+		{"x*abc", "", "abc", "abc"},
+		{"\\d+", "", "", ""},
+	} {
+		re := FromInfixExp(test.pattern)
+		lits := literalSets(&re)
+		for _, got := range []struct {
+			name string
+			lits []string
+			want string
+		}{
+			{"prefix", lits.prefix, test.prefix},
+			{"suffix", lits.suffix, test.suffix},
+			{"inner", lits.inner, test.inner},
+		} {
+			if minLen(got.lits) == 0 {
+				got.lits = nil
+			}
+			if strings.Join(got.lits, ",") != got.want {
+				t.Errorf("error: %s of %q\ngot: %q\nwant: %q",
+					got.name, test.pattern, got.lits, got.want)
+			}
+		}
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		prefix   string
+		complete bool
+	}{
+		{"abc", "abc", true},
+		{"abc\\d", "abc", false},
+		{"^abc", "abc", false},
+		{"(abc)", "abc", false},
+		{"a|b", "", false},
+	} {
+		re := FromInfixExp(test.pattern)
+		prefix, complete := re.LiteralPrefix()
+		if prefix != test.prefix || complete != test.complete {
+			t.Errorf("error: %q\ngot: %q, %v\nwant: %q, %v",
+				test.pattern, prefix, complete, test.prefix, test.complete)
+		}
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	input := strings.Repeat("noise noise noise ", 100) + "ERROR: disk full " +
+		strings.Repeat("more noise ", 100) + "ERROR: again"
+	re := FromInfixExp("ERROR: \\w+")
+	got := re.FindAllString(input, -1, false)
+	if want := "ERROR: disk,ERROR: again"; strings.Join(got, ",") != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, want)
+	}
+	if !re.MatchString(input, false) {
+		t.Errorf("error: no match in input")
+	}
+	if re.MatchString(strings.Repeat("noise ", 100), false) {
+		t.Errorf("error: unexpected match in noise")
+	}
+	if !re.MatchString("error: mixed", true) {
+		t.Errorf("error: case-insensitive match skipped by prefilter")
+	}
+}
+
+func BenchmarkPrefilter(b *testing.B) {
+	b.StopTimer()
+	re := FromInfixExp("user_id=\\d+")
+	input := strings.Repeat("GET /index.html 200 ", 5000) + "user_id=42"
+	b.SetBytes(int64(len(input)))
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if re.FindAllString(input, -1, false) == nil {
+			b.Fatal("no match")
+		}
+	}
+}
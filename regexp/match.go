@@ -0,0 +1,58 @@
+package regexp
+
+// A Match describes a single match of a Regexp in a string.
+type Match struct {
+	str   string
+	index []int // pairs of byte offsets, the first pair spans the whole match
+}
+
+// Start returns the byte offset of the beginning of the match.
+func (m Match) Start() int {
+	return m.index[0]
+}
+
+// End returns the byte offset of the end of the match.
+func (m Match) End() int {
+	return m.index[1]
+}
+
+// Text returns the matched text.
+func (m Match) Text() string {
+	return m.str[m.index[0]:m.index[1]]
+}
+
+// NumGroups returns the number of capture groups of the match.
+func (m Match) NumGroups() int {
+	return len(m.index)/2 - 1
+}
+
+// GroupIndex returns the byte offsets of the n-th capture group.
+// Group 0 is the whole match. If the group did not participate
+// in the match, GroupIndex returns -1, -1.
+func (m Match) GroupIndex(n int) (start, end int) {
+	if n < 0 || 2*n+1 >= len(m.index) {
+		return -1, -1
+	}
+	return m.index[2*n], m.index[2*n+1]
+}
+
+// Group returns the text of the n-th capture group.
+// Group 0 is the whole match. If the group did not participate
+// in the match, Group returns the empty string.
+func (m Match) Group(n int) string {
+	start, end := m.GroupIndex(n)
+	if start < 0 {
+		return ""
+	}
+	return m.str[start:end]
+}
+
+// Groups returns the text of the match followed by the text of
+// every capture group.
+func (m Match) Groups() []string {
+	groups := make([]string, m.NumGroups()+1)
+	for n := range groups {
+		groups[n] = m.Group(n)
+	}
+	return groups
+}
@@ -0,0 +1,129 @@
+package regexp
+
+// Minimize returns the automaton with the fewest states accepting the
+// same strings as a, computed with Hopcroft's partition refinement.
+func (a *Automaton) Minimize() *Automaton {
+	n, k := len(a.next), len(a.lo)
+
+	// prev[c][t] lists the states moving to t on class c.
+	prev := make([][][]int, k)
+	for c := range prev {
+		prev[c] = make([][]int, n)
+	}
+	for s, next := range a.next {
+		for c, t := range next {
+			prev[c][t] = append(prev[c][t], s)
+		}
+	}
+
+	// Start with the accepting and the rejecting states.
+	var blocks [][]int
+	block := make([]int, n)
+	var accepting, rejecting []int
+	for s, ok := range a.accept {
+		if ok {
+			accepting = append(accepting, s)
+		} else {
+			rejecting = append(rejecting, s)
+		}
+	}
+	for _, b := range [][]int{accepting, rejecting} {
+		if len(b) == 0 {
+			continue
+		}
+		for _, s := range b {
+			block[s] = len(blocks)
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Refine the blocks by the predecessors of the blocks in work.
+	work := make([]int, 0, len(blocks))
+	inWork := make([]bool, len(blocks), n)
+	for b := range blocks {
+		work = append(work, b)
+		inWork[b] = true
+	}
+	count := make([]int, n)
+	marked := make([]bool, n)
+	for len(work) > 0 {
+		splitter := blocks[work[len(work)-1]]
+		inWork[work[len(work)-1]] = false
+		work = work[:len(work)-1]
+		for c := range k {
+			var touched, states []int
+			for _, t := range splitter {
+				for _, s := range prev[c][t] {
+					b := block[s]
+					if count[b] == 0 {
+						touched = append(touched, b)
+					}
+					count[b]++
+					marked[s] = true
+					states = append(states, s)
+				}
+			}
+			for _, b := range touched {
+				if count[b] == len(blocks[b]) {
+					count[b] = 0
+					continue
+				}
+				count[b] = 0
+				// Move the marked states of b into a new block.
+				nb := len(blocks)
+				var in, out []int
+				for _, s := range blocks[b] {
+					if marked[s] {
+						in = append(in, s)
+					} else {
+						out = append(out, s)
+					}
+				}
+				blocks[b] = out
+				blocks = append(blocks, in)
+				inWork = append(inWork, false)
+				for _, s := range in {
+					block[s] = nb
+				}
+				switch {
+				case inWork[b]:
+					work = append(work, nb)
+					inWork[nb] = true
+				case len(in) < len(out):
+					work = append(work, nb)
+					inWork[nb] = true
+				default:
+					work = append(work, b)
+					inWork[b] = true
+				}
+			}
+			for _, s := range states {
+				marked[s] = false
+			}
+		}
+	}
+
+	// Number the blocks in order of discovery from the start state.
+	id := make([]int, len(blocks))
+	for b := range id {
+		id[b] = -1
+	}
+	m := &Automaton{lo: a.lo}
+	order := []int{block[0]}
+	id[block[0]] = 0
+	for j := 0; j < len(order); j++ {
+		s := blocks[order[j]][0]
+		next := make([]int, k)
+		for c, t := range a.next[s] {
+			b := block[t]
+			if id[b] < 0 {
+				id[b] = len(order)
+				order = append(order, b)
+			}
+			next[c] = id[b]
+		}
+		m.next = append(m.next, next)
+		m.accept = append(m.accept, a.accept[s])
+	}
+	return m
+}
@@ -0,0 +1,103 @@
+package regexp
+
+import (
+	"errors"
+	"testing"
+)
+
+func mustRegexp(t *testing.T, a *Automaton) *Regexp {
+	t.Helper()
+	re, err := a.Regexp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return re
+}
+
+func TestMinimize(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		n    int
+	}{
+		{"a", 2},
+		{"^a$", 4},
+		{"abc", 4},
+		{"a|b|c", 2},
+		{"x[0-9]{2}", 4},
+		{"(a|b)*c", 2},
+	} {
+		a := mustAutomaton(t, test.expr)
+		m := a.Minimize()
+		if m.NumStates() != test.n {
+			t.Errorf("error: states of %q\ngot: %d\nwant: %d", test.expr, m.NumStates(), test.n)
+		}
+		if ok, witness := Equivalent(a, m); !ok {
+			t.Errorf("error: %q changed by Minimize\ngot: %q\nwant: equivalent", test.expr, witness)
+		}
+		if mm := m.Minimize(); mm.NumStates() != m.NumStates() {
+			t.Errorf("error: states of %q minimized twice\ngot: %d\nwant: %d", test.expr, mm.NumStates(), m.NumStates())
+		}
+	}
+}
+
+func TestAutomatonRegexp(t *testing.T) {
+	for _, test := range []struct {
+		expr, want string
+	}{
+		{"a", "[^a]*a.*"},
+		{"a|b", "[^ab]*[ab].*"},
+		{`\bb`, `(\W|[^\Wb]\w*\W)*b.*`},
+	} {
+		if got := mustRegexp(t, mustAutomaton(t, test.expr)).String(); got != test.want {
+			t.Errorf("error: %q\ngot: %s\nwant: %s", test.expr, got, test.want)
+		}
+	}
+
+	// The patterns have no assertions, so their languages are closed
+	// under extension and survive the round trip through String.
+	for _, expr := range []string{
+		"abc", "a|bc", "x[0-9]{2}", "(a|b)*c", "a+b+", "(foo|bar)baz", "[a-c][b-d]",
+	} {
+		a := mustAutomaton(t, expr)
+		re := mustRegexp(t, a)
+		b := mustAutomaton(t, re.String())
+		if ok, witness := Equivalent(a, b); !ok {
+			t.Errorf("error: %q converted to %s\ngot: %q\nwant: equivalent", expr, re, witness)
+		}
+	}
+
+	// Matched against whole strings, the tree accepts what a accepts.
+	for _, expr := range []string{"^a$", "^(foo|bar)$", `\bx`, `\b.(b[ab]|b?(^[ab]?|b^))|b+a`} {
+		a := mustAutomaton(t, expr)
+		re := mustRegexp(t, a)
+		if _, err := Compile(re.String()); err != nil {
+			t.Errorf("error: %s from %q\ngot: %v\nwant: no error", re, expr, err)
+		}
+		if s := re.String(); len(s) > 4096 {
+			t.Errorf("error: %q converted to %d bytes\ngot: %.80s...\nwant: at most 4096", expr, len(s), s)
+		}
+		re.Longest()
+		for _, str := range []string{"", "a", "ba", "a\n", "b\na", "foo", "bar\nx", "foox", "x", "ax", " x"} {
+			m := re.FindStringIndex(str, false)
+			if got, want := m != nil && m[0] == 0 && m[1] == len(str), a.Accepts(str); got != want {
+				t.Errorf("error: %s from %q on %q\ngot: %v\nwant: %v", re, expr, str, got, want)
+			}
+		}
+	}
+
+	empty := Intersect(mustAutomaton(t, "a"), Complement(mustAutomaton(t, "a")))
+	if re := mustRegexp(t, empty); re.MatchString("a", false) || re.MatchString("", false) {
+		t.Errorf("error: %s of the empty language matches", re)
+	}
+}
+
+func TestAutomatonRegexpTooLarge(t *testing.T) {
+	// The first tree has too many nodes, the second prints as
+	// a pattern longer than DefaultLimits.MaxLen.
+	for _, expr := range []string{"^[ab]*a[ab]{6}$", `^\w*a\w{3}$`} {
+		a := mustAutomaton(t, expr)
+		if re, err := a.Regexp(); !errors.Is(err, ErrRegexpTooLarge) {
+			t.Errorf("error: %q converted\ngot: %.80v, %v\nwant: %v", expr, re, err, ErrRegexpTooLarge)
+		}
+	}
+}
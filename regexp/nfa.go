@@ -0,0 +1,251 @@
+package regexp
+
+// A queue is a sparse set of instruction indices holding the
+// threads of the NFA simulation in priority order.
+type queue struct {
+	sparse []uint32
+	dense  []entry
+}
+
+// An entry is an instruction index in a queue and, for InstRune and
+// InstMatch instructions, the thread positioned there.
+type entry struct {
+	pc uint32
+	t  *thread
+}
+
+// A thread is a single path through the program,
+// carrying the capture positions recorded so far.
+type thread struct {
+	cap []int
+}
+
+func newQueue(n int) *queue {
+	return &queue{sparse: make([]uint32, n), dense: make([]entry, 0, n)}
+}
+
+func (q *queue) contains(pc uint32) bool {
+	j := q.sparse[pc]
+	return j < uint32(len(q.dense)) && q.dense[j].pc == pc
+}
+
+func (q *queue) add(pc uint32) *entry {
+	q.sparse[pc] = uint32(len(q.dense))
+	q.dense = append(q.dense, entry{pc: pc})
+	return &q.dense[len(q.dense)-1]
+}
+
+func (q *queue) clear() {
+	q.dense = q.dense[:0]
+}
+
+// A machine holds the state of an NFA simulation.
+type machine struct {
+	call
+	p        *Prog
+	longest  bool
+	anchored bool // only start a thread at the initial position
+	ncap     int
+	q0, q1   *queue
+	pool     []*thread
+	matched  bool
+	matchcap []int
+}
+
+func newMachine(p *Prog, ncap int, longest bool, c call) *machine {
+	return &machine{
+		call:     c,
+		p:        p,
+		longest:  longest,
+		ncap:     ncap,
+		q0:       newQueue(len(p.Inst)),
+		q1:       newQueue(len(p.Inst)),
+		matchcap: make([]int, ncap),
+	}
+}
+
+func (m *machine) alloc() *thread {
+	if n := len(m.pool); n > 0 {
+		t := m.pool[n-1]
+		m.pool = m.pool[:n-1]
+		return t
+	}
+	return &thread{cap: make([]int, m.ncap)}
+}
+
+func (m *machine) free(t *thread) {
+	m.pool = append(m.pool, t)
+}
+
+// doNFA finds the leftmost match of re in str starting the search at pos.
+// It returns the positions of the match followed by the positions of the
+// first ncap capture groups, or nil if there is no match.
+func (re *Regexp) doNFA(str string, pos int, ncap int, c call) []int {
+	m := newMachine(re.getProg(), 2*(ncap+1), re.longest, c)
+	if !m.match(str, pos) {
+		return nil
+	}
+	return append([]int(nil), m.matchcap[:2*(ncap+1)]...)
+}
+
+// match runs the machine over str starting at pos.
+// It reports whether a match was found and leaves its
+// capture positions in m.matchcap.
+func (m *machine) match(str string, pos int) bool {
+	m.matched = false
+	for i := range m.matchcap {
+		m.matchcap[i] = -1
+	}
+	runq, nextq := m.q0, m.q1
+	pos0 := pos
+	r0, _ := step(str, pos-1)
+	if pos == 0 {
+		r0 = endOfText
+	}
+	r1, w1 := step(str, pos)
+	cap := make([]int, m.ncap)
+	for {
+		m.tick()
+		if len(runq.dense) == 0 && m.matched {
+			// Leftmost match found and no higher-priority threads left.
+			break
+		}
+		if len(runq.dense) == 0 && m.anchored && pos != pos0 {
+			break
+		}
+		if !m.matched && (!m.anchored || pos == pos0) {
+			// Start a new thread at this position.
+			for i := range cap {
+				cap[i] = -1
+			}
+			cap[0] = pos
+			m.trace(m.p, EventStart, uint32(m.p.Start), pos, 0)
+			m.add(runq, uint32(m.p.Start), pos, cap, emptyOpContext(r0, r1), nil)
+		}
+		m.step(runq, nextq, pos, pos+w1, r1, emptyOpContext(r1, peekRune(str, pos+w1)))
+		if w1 == 0 {
+			break
+		}
+		pos += w1
+		r0 = r1
+		r1, w1 = step(str, pos)
+		runq, nextq = nextq, runq
+	}
+	m.clearQueue(nextq)
+	return m.matched
+}
+
+// peekRune returns the rune at pos or endOfText.
+func peekRune(str string, pos int) rune {
+	r, _ := step(str, pos)
+	return r
+}
+
+func (m *machine) clearQueue(q *queue) {
+	for _, d := range q.dense {
+		if d.t != nil {
+			m.free(d.t)
+		}
+	}
+	q.clear()
+}
+
+// step executes one step of the machine, running the threads in runq
+// on the rune c at position pos and adding the surviving threads
+// to nextq at position nextPos with the zero-width context nextCond.
+func (m *machine) step(runq, nextq *queue, pos, nextPos int, c rune, nextCond EmptyOp) {
+	for j := 0; j < len(runq.dense); j++ {
+		d := &runq.dense[j]
+		t := d.t
+		if t == nil {
+			continue
+		}
+		if m.longest && m.matched && m.matchcap[0] < t.cap[0] {
+			m.free(t)
+			continue
+		}
+		i := &m.p.Inst[d.pc]
+		add := false
+		switch i.Op {
+		case InstMatch:
+			m.trace(m.p, EventAccept, d.pc, pos, 0)
+			if !m.longest || !m.matched || t.cap[0] < m.matchcap[0] ||
+				t.cap[0] == m.matchcap[0] && m.matchcap[1] < pos {
+				t.cap[1] = pos
+				copy(m.matchcap, t.cap)
+			}
+			if !m.longest {
+				// Leftmost-first: cut off the lower-priority threads.
+				for _, d := range runq.dense[j+1:] {
+					if d.t != nil {
+						m.free(d.t)
+					}
+				}
+				runq.dense = runq.dense[:0]
+			}
+			m.matched = true
+		case InstRune:
+			add = i.matchRune(c, m.fold)
+			if add {
+				m.trace(m.p, EventConsume, d.pc, pos, c)
+			} else {
+				m.trace(m.p, EventFail, d.pc, pos, c)
+			}
+		}
+		if add {
+			t = m.add(nextq, i.Out, nextPos, t.cap, nextCond, t)
+		}
+		if t != nil {
+			m.free(t)
+		}
+	}
+	runq.clear()
+}
+
+// add adds an entry to q for pc, following empty-width instructions
+// that are satisfied by cond. If t is non-nil it is reused for the
+// new entry; add returns t if it was not used.
+func (m *machine) add(q *queue, pc uint32, pos int, cap []int, cond EmptyOp, t *thread) *thread {
+	if pc == 0 || q.contains(pc) {
+		return t
+	}
+	d := q.add(pc)
+	i := &m.p.Inst[pc]
+	switch i.Op {
+	case InstFail:
+	case InstAlt:
+		m.trace(m.p, EventEnter, pc, pos, 0)
+		t = m.add(q, i.Out, pos, cap, cond, t)
+		t = m.add(q, i.Arg, pos, cap, cond, t)
+	case InstEmptyWidth:
+		if EmptyOp(i.Arg)&^cond == 0 {
+			m.trace(m.p, EventAssert, pc, pos, 0)
+			t = m.add(q, i.Out, pos, cap, cond, t)
+		} else {
+			m.trace(m.p, EventAssertFail, pc, pos, 0)
+		}
+	case InstNop:
+		m.trace(m.p, EventEnter, pc, pos, 0)
+		t = m.add(q, i.Out, pos, cap, cond, t)
+	case InstCapture:
+		m.trace(m.p, EventEnter, pc, pos, 0)
+		if int(i.Arg) < len(cap) {
+			old := cap[i.Arg]
+			cap[i.Arg] = pos
+			m.add(q, i.Out, pos, cap, cond, nil)
+			cap[i.Arg] = old
+		} else {
+			t = m.add(q, i.Out, pos, cap, cond, t)
+		}
+	case InstMatch, InstRune:
+		if t == nil {
+			t = m.alloc()
+		}
+		if &t.cap[0] != &cap[0] {
+			copy(t.cap, cap)
+		}
+		d.t = t
+		t = nil
+	}
+	return t
+}
@@ -0,0 +1,211 @@
+package regexp
+
+import "strings"
+
+// A onePass is a program in which at every position at most one
+// instruction can consume the next rune, so a match can be found
+// with captures in a single forward scan without any thread list.
+//
+// Every state of the machine is an instruction where a thread may be
+// waiting for the next rune: the start instruction or the target of
+// an InstRune. Its edges are the InstRune and InstMatch instructions
+// reachable from it through empty-width instructions.
+type onePass struct {
+	p     *Prog
+	edges map[uint32][]onePassEdge
+}
+
+// A onePassEdge is a path from a state to an InstRune or InstMatch
+// instruction, with the assertions and captures along the way.
+type onePassEdge struct {
+	pc   uint32
+	cond EmptyOp
+	caps []uint32
+}
+
+// compileOnePass returns the one-pass form of p, or nil if p is not
+// one-pass. The program must be anchored at a line start and the
+// instructions reachable from each state must be unambiguous: no two
+// of them consume a common rune, and a match competes with consuming
+// a rune only behind a $ that no such rune can satisfy.
+func compileOnePass(p *Prog) *onePass {
+	op := &onePass{p: p, edges: make(map[uint32][]onePassEdge)}
+	todo := []uint32{uint32(p.Start)}
+	for len(todo) > 0 {
+		pc := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if _, ok := op.edges[pc]; ok {
+			continue
+		}
+		edges, ok := op.closure(pc, 0, nil, make(map[uint32]bool))
+		if !ok || !unambiguous(p, edges) {
+			return nil
+		}
+		op.edges[pc] = edges
+		for _, e := range edges {
+			if i := &p.Inst[e.pc]; i.Op == InstRune {
+				todo = append(todo, i.Out)
+			}
+		}
+	}
+	if !anchoredStart(p) {
+		return nil
+	}
+	return op
+}
+
+// closure collects the edges leaving pc. It fails if an instruction
+// is reached twice, since the paths to it would be ambiguous.
+func (op *onePass) closure(pc uint32, cond EmptyOp, caps []uint32, seen map[uint32]bool) ([]onePassEdge, bool) {
+	if pc == 0 {
+		return nil, true
+	}
+	if seen[pc] {
+		return nil, false
+	}
+	seen[pc] = true
+	i := &op.p.Inst[pc]
+	switch i.Op {
+	case InstAlt:
+		e0, ok0 := op.closure(i.Out, cond, caps, seen)
+		e1, ok1 := op.closure(i.Arg, cond, caps, seen)
+		return append(e0, e1...), ok0 && ok1
+	case InstNop:
+		return op.closure(i.Out, cond, caps, seen)
+	case InstEmptyWidth:
+		return op.closure(i.Out, cond|EmptyOp(i.Arg), caps, seen)
+	case InstCapture:
+		return op.closure(i.Out, cond, append(caps[:len(caps):len(caps)], i.Arg), seen)
+	case InstRune, InstMatch:
+		return []onePassEdge{{pc: pc, cond: cond, caps: caps}}, true
+	}
+	return nil, true
+}
+
+// unambiguous reports whether at most one of the edges can be taken
+// for any input.
+func unambiguous(p *Prog, edges []onePassEdge) bool {
+	for j, e0 := range edges {
+		i0 := &p.Inst[e0.pc]
+		for _, e1 := range edges[j+1:] {
+			i1 := &p.Inst[e1.pc]
+			switch {
+			case i0.Op == InstRune && i1.Op == InstRune:
+				if overlaps(i0.Rune, i1.Rune) {
+					return false
+				}
+			case i0.Op == InstMatch && i1.Op == InstMatch:
+				return false
+			default:
+				m, r := e0, i1
+				if i0.Op == InstRune {
+					m, r = e1, i0
+				}
+				if m.cond&EmptyLineEnd == 0 || r.Rune.matchRunePos('\n', false) != noMatch {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// overlaps reports whether the rune sets rr0 and rr1 intersect.
+func overlaps(rr0, rr1 RuneRange) bool {
+	if len(rr0) == 1 {
+		rr0 = RuneRange{rr0[0], rr0[0]}
+	}
+	if len(rr1) == 1 {
+		rr1 = RuneRange{rr1[0], rr1[0]}
+	}
+	for i := 0; i < len(rr0); i += 2 {
+		for j := 0; j < len(rr1); j += 2 {
+			if rr0[i] <= rr1[j+1] && rr1[j] <= rr0[i+1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getOnePass returns the one-pass form of the program of re,
+// or nil if it is not one-pass.
+func (re *Regexp) getOnePass() *onePass {
+	re.onePassOnce.Do(func() {
+		re.onePass = compileOnePass(re.getProg())
+	})
+	return re.onePass
+}
+
+// doOnePass finds the leftmost match in str at or after pos with the
+// one-pass machine, trying every line start in turn. It returns the
+// positions of the match followed by the positions of the first ncap
+// capture groups, or nil if there is no match. If the input turns out
+// to be ambiguous under case folding, ok is false.
+func (re *Regexp) doOnePass(str string, pos int, ncap int, c call) (matches []int, ok bool) {
+	op := re.getOnePass()
+	for pos <= len(str) {
+		if pos == 0 || str[pos-1] == '\n' {
+			matches, ok = op.match(str, pos, ncap, c)
+			if matches != nil || !ok {
+				return matches, ok
+			}
+		}
+		nl := -1
+		if pos < len(str) {
+			nl = strings.IndexByte(str[pos:], '\n')
+		}
+		if nl < 0 {
+			break
+		}
+		pos += nl + 1
+	}
+	return nil, true
+}
+
+// match runs the one-pass machine anchored at pos as part of the call c.
+func (op *onePass) match(str string, pos int, ncap int, c call) (matches []int, ok bool) {
+	cap := make([]int, 2*(ncap+1))
+	for i := range cap {
+		cap[i] = -1
+	}
+	r0, _ := step(str, pos-1)
+	if pos == 0 {
+		r0 = endOfText
+	}
+	pc := uint32(op.p.Start)
+	for {
+		c.tick()
+		r1, w1 := step(str, pos)
+		cond := emptyOpContext(r0, r1)
+		var next *onePassEdge
+		for j := range op.edges[pc] {
+			e := &op.edges[pc][j]
+			if e.cond&^cond != 0 {
+				continue
+			}
+			i := &op.p.Inst[e.pc]
+			if i.Op == InstMatch || i.matchRune(r1, c.fold) {
+				if next != nil {
+					return nil, false
+				}
+				next = e
+			}
+		}
+		if next == nil {
+			return nil, true
+		}
+		for _, slot := range next.caps {
+			if int(slot) < len(cap) {
+				cap[slot] = pos
+			}
+		}
+		i := &op.p.Inst[next.pc]
+		if i.Op == InstMatch {
+			return cap, true
+		}
+		pc = i.Out
+		pos += w1
+		r0 = r1
+	}
+}
@@ -0,0 +1,83 @@
+package regexp
+
+import (
+	stdregexp "regexp"
+	"testing"
+)
+
+func TestCompileOnePass(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		onePass bool
+	}{
+		{"^[a-z]+=[0-9]+$", true},
+		{"^(a|b)c$", true},
+		{"^a*b", true},
+		{"^(ab)*c$", true},
+		{"^x(y)?z", true},
+		{"^\\d+-\\d+$", true},
+		{"[a-z]+=[0-9]+", false},
+		{"^a*a", false},
+		{"^(a|ab)c", false},
+		{"^a+", false},
+		{"^(ab)*", false},
+		{"^(a*)*b", false},
+	} {
+		re := FromInfixExp(test.pattern)
+		if got := re.getOnePass() != nil; got != test.onePass {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.pattern, got, test.onePass)
+		}
+	}
+}
+
+func TestOnePass(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+	}{
+		{"^[a-z]+=[0-9]+$", "key=123"},
+		{"^[a-z]+=[0-9]+$", "key=12x"},
+		{"^[a-z]+=[0-9]+$", "# comment\nkey=123\nrest"},
+		{"^([a-z]+)=([0-9]+)$", "a=1\nbb=22"},
+		{"^(a|b)c$", "bc"},
+		{"^x(y)?z", "xz"},
+		{"^x(y)?z", "xyz"},
+		{"^(ab)*c$", "ababc"},
+		{"^(ab)*c$", "abac"},
+		{"^\\w+ \\w+$", "hello world"},
+	} {
+		re := FromInfixExp(test.pattern)
+		if re.getOnePass() == nil {
+			t.Fatalf("error: %q is not one-pass", test.pattern)
+		}
+		want := stdregexp.MustCompile("(?m)" + test.pattern).FindStringSubmatchIndex(test.input)
+		got, ok := re.doOnePass(test.input, 0, re.NumSubexp(), call{})
+		if !ok || !intsEqual(got, want) {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+		}
+	}
+}
+
+func TestOnePassFold(t *testing.T) {
+	// [a] and [A] are disjoint, but both match under case folding.
+	re := FromInfixExp("^(a|A)x")
+	if re.getOnePass() == nil {
+		t.Fatalf("error: %q is not one-pass", "^(a|A)x")
+	}
+	_, ok := re.doOnePass("ax", 0, 1, call{fold: true})
+	if ok {
+		t.Errorf("error: ambiguous input accepted under case folding")
+	}
+	if got := re.FindStringIndex("ax", true); !intsEqual(got, []int{0, 2}) {
+		t.Errorf("error:\ngot: %v\nwant: %v", got, []int{0, 2})
+	}
+}
+
+func BenchmarkOnePass(b *testing.B) {
+	re := FromInfixExp("^([a-z]+)=([0-9]+)$")
+	input := "abcdefghijklmnopqrstuvwxyz=0123456789"
+	for i := 0; i < b.N; i++ {
+		if m, _ := re.doOnePass(input, 0, 2, call{}); m == nil {
+			b.Fatal("no match")
+		}
+	}
+}
@@ -8,33 +8,59 @@ import (
 	"github.com/tautastic/rex/utils"
 )
 
-func union(first, second *Regexp) *Regexp {
-	return &Regexp{Op: OpAlternate, Sub: []*Regexp{first, second}}
+// A converter holds the state of a single conversion of a syntax tree,
+// so that patterns can be compiled from several goroutines at once.
+type converter struct {
+	numCap   int    // capture groups seen so far
+	limits   Limits // bounds the pattern converted
+	depth    int    // current nesting of groups and repetitions
+	numClass int    // character classes seen so far
+}
+
+// nest enters a group or repetition.
+func (cv *converter) nest() {
+	cv.depth++
+	if cv.limits.MaxDepth > 0 && cv.depth > cv.limits.MaxDepth {
+		panic(utils.ErrNestingTooDeep)
+	}
 }
 
-func concat(first, second *Regexp) *Regexp {
-	re := &Regexp{Op: OpConcat, Sub: []*Regexp{}}
-	if first.Op == OpConcat {
-		re.Sub = append(re.Sub, second)
-		re.Sub = append(re.Sub, first.Sub...)
-	} else if second.Op == OpConcat {
-		re.Sub = append(re.Sub, first)
-		re.Sub = append(re.Sub, second.Sub...)
-	} else {
-		re.Sub = []*Regexp{first, second}
+// class counts a character class.
+func (cv *converter) class(re *Regexp) *Regexp {
+	cv.numClass++
+	if cv.limits.MaxClasses > 0 && cv.numClass > cv.limits.MaxClasses {
+		panic(utils.ErrTooManyClasses)
 	}
 	return re
 }
 
-func repeat(sub0 *Regexp, quant *syntax.Node) *Regexp {
+// capture numbers the group before its contents so that
+// groups are indexed in the order of their opening parentheses.
+func (cv *converter) capture(dis *syntax.Node) *Regexp {
+	cv.numCap++
+	re := &Regexp{Op: OpCapture, Cap: cv.numCap}
+	cv.nest()
+	re.Sub = []*Regexp{cv.fromSyntaxTree(dis)}
+	cv.depth--
+	return re
+}
+
+func union(first, second *Regexp) *Regexp {
+	return &Regexp{Op: OpAlternate, Sub: []*Regexp{first, second}}
+}
+
+func (cv *converter) repeat(sub0 *Regexp, quant *syntax.Node) *Regexp {
 	if len(quant.Sub) != 2 {
 		panic(utils.ErrInvalidRepeatSize)
 	}
-	lower, err := strconv.Atoi(quant.Sub[0].Label)
-	upper, err := strconv.Atoi(quant.Sub[1].Label)
+	lower, err := strconv.Atoi(quant.Sub[0].Value)
+	upper, err := strconv.Atoi(quant.Sub[1].Value)
 	if err != nil || (upper < lower && upper != -1) {
 		panic(utils.ErrInvalidRepeatSize)
 	}
+	if cv.limits.MaxRepeat > 0 && max(lower, upper) > cv.limits.MaxRepeat {
+		panic(utils.ErrRepeatTooLarge)
+	}
 	return &Regexp{Op: OpRepeat, Min: lower, Max: upper, Sub: []*Regexp{sub0}}
 }
 
@@ -57,83 +83,76 @@ func ctrlToRune(ch uint8) rune {
 func hexSeqToRune(str string) rune {
 	dec, err := strconv.ParseInt(str, 16, 32)
 	if err != nil {
-		panic("error: invalid hexadecimal number")
+		panic(utils.ErrInvalidEscape)
 	}
 	return rune(dec)
 }
 
 func classRange(rr RuneRange, node0, node1 *syntax.Node) RuneRange {
-	switch node0.Label {
-	default:
-		panic(utils.ErrUnexpectedSymbol)
+	return appendRange(rr, classAtomRune(node0), classAtomRune(node1))
+}
 
-	case "Literal":
-		lo, _ := utf8.DecodeRuneInString(node0.Sub[0].Label)
-		hi, _ := utf8.DecodeRuneInString(node1.Sub[0].Label)
-		rr = appendRange(rr, lo, hi)
-	case "Control":
-		lo := ctrlToRune(node0.Sub[0].Label[0])
-		hi := ctrlToRune(node1.Sub[0].Label[0])
-		rr = appendRange(rr, lo, hi)
-	case "HexSeq":
-		lo := hexSeqToRune(node0.Sub[0].Label)
-		hi := hexSeqToRune(node1.Sub[0].Label)
-		rr = appendRange(rr, lo, hi)
+// classAtomRune returns the rune of a class atom bounding a range.
+func classAtomRune(node *syntax.Node) rune {
+	switch node.Kind {
+	case syntax.KindLiteral:
+		r, _ := utf8.DecodeRuneInString(node.Value)
+		return r
+	case syntax.KindControl:
+		return ctrlToRune(node.Value[0])
+	case syntax.KindHexSeq:
+		return hexSeqToRune(node.Value)
 	}
-	return rr
+	panic(utils.ErrUnexpectedSymbol)
 }
 
-func fromPerl(ch uint8) *Regexp {
-	return &Regexp{Op: OpCharClass, Sym: PerlClass[ch]}
+func (cv *converter) fromPerl(ch uint8) *Regexp {
+	return cv.class(&Regexp{Op: OpCharClass, Sym: PerlClass[ch]})
 }
 
 func fromControl(ch uint8) *Regexp {
-	re := &Regexp{Op: OpLiteral, Sym: RuneRange{}}
-	re.Sym = appendLiteral(re.Sym, ctrlToRune(ch))
-
-	return re
+	return fromRune(ctrlToRune(ch))
 }
 
 func fromHexSeq(str string) *Regexp {
 	return fromRune(hexSeqToRune(str))
 }
 
-func fromUniSeq(str string) *Regexp {
+// uniClass returns the Unicode class named by str,
+// negated if str starts with '^'.
+func uniClass(str string) RuneRange {
+	if len(str) > 0 && str[0] == '^' {
+		return negateClass(append(RuneRange(nil), UniClass[str[1:]]...))
+	}
+	return UniClass[str]
+}
+
+func (cv *converter) fromUniSeq(str string) *Regexp {
 	re := &Regexp{Op: OpCharClass}
-	re.Sym = UniClass[str]
-	return re
+	re.Sym = uniClass(str)
+	return cv.class(re)
 }
 
-func fromClass(children []*syntax.Node) *Regexp {
+func (cv *converter) fromClass(children []*syntax.Node) *Regexp {
 	re := &Regexp{Op: OpCharClass}
-	for i, child := range children {
-		switch child.Label {
+	for _, child := range children {
+		switch child.Kind {
 
 		default:
 			panic(utils.ErrUnexpectedSymbol)
 
-		case "Literal", "^":
-			if i != 0 || child.Sub[0].Label != "^" {
-				lo, _ := utf8.DecodeRuneInString(child.Sub[0].Label)
-				re.Sym = appendLiteral(re.Sym, lo)
-			}
-
-		case "Control":
-			ctrl := ctrlToRune(child.Sub[0].Label[0])
-			re.Sym = appendLiteral(re.Sym, ctrl)
+		case syntax.KindNegation:
 
-		case "Perl":
-			re.Sym = appendClass(re.Sym, PerlClass[child.Sub[0].Label[0]])
+		case syntax.KindLiteral, syntax.KindControl, syntax.KindHexSeq:
+			re.Sym = appendLiteral(re.Sym, classAtomRune(child))
 
-		case "HexSeq":
-			hseq := hexSeqToRune(child.Sub[0].Label)
-			re.Sym = appendLiteral(re.Sym, hseq)
+		case syntax.KindPerl:
+			re.Sym = appendClass(re.Sym, PerlClass[child.Value[0]])
 
-		case "UniSeq":
-			useq := UniClass[child.Sub[0].Label]
-			re.Sym = appendClass(re.Sym, useq)
+		case syntax.KindUniSeq:
+			re.Sym = appendClass(re.Sym, uniClass(child.Value))
 
-		case "ClassRange":
+		case syntax.KindClassRange:
 			if len(child.Sub) == 2 {
 				re.Sym = classRange(re.Sym, child.Sub[0], child.Sub[1])
 			}
@@ -141,10 +160,10 @@ func fromClass(children []*syntax.Node) *Regexp {
 		}
 	}
 	re.Sym = cleanClass(&re.Sym)
-	if children[0].Label == "^" {
+	if len(children) > 0 && children[0].Kind == syntax.KindNegation {
 		re.Sym = negateClass(re.Sym)
 	}
-	return re
+	return cv.class(re)
 }
 
 func fromRune(r rune) *Regexp {
@@ -173,69 +192,151 @@ func fromAssertion(ch uint8) *Regexp {
 	}
 }
 
-func fromSyntaxTree(root *syntax.Node) *Regexp {
-	if root.Sub != nil {
-		switch root.Label {
-		case "Disjunction":
-			term := fromSyntaxTree(root.Sub[0])
-			if len(root.Sub) == 2 {
-				return union(term, fromSyntaxTree(root.Sub[1]))
-			}
-			return term
+func (cv *converter) fromSyntaxTree(root *syntax.Node) *Regexp {
+	switch root.Kind {
+	case syntax.KindDisjunction:
+		terms := cv.fromChain(root)
+		re := terms[len(terms)-1]
+		for j := len(terms) - 2; j >= 0; j-- {
+			re = union(terms[j], re)
+		}
+		return re
 
-		case "Term":
-			factor := fromSyntaxTree(root.Sub[0])
-			if len(root.Sub) == 2 {
-				return concat(factor, fromSyntaxTree(root.Sub[1]))
-			}
-			return factor
+	case syntax.KindTerm:
+		factors := cv.fromChain(root)
+		if len(factors) == 1 {
+			return factors[0]
+		}
+		return &Regexp{Op: OpConcat, Sub: factors}
+
+	case syntax.KindFactor:
+		if len(root.Sub) == 2 {
+			cv.nest()
+			atom := cv.fromSyntaxTree(root.Sub[0])
+			cv.depth--
+			return cv.repeat(atom, root.Sub[1])
+		}
+		return cv.fromSyntaxTree(root.Sub[0])
 
-		case "Factor":
-			// Check for assertion missing.
-			if root.Sub[0].Label == "Assertion" {
-				return fromAssertion(root.Sub[0].Sub[0].Label[0])
-			}
-			atom := fromSyntaxTree(root.Sub[0])
-			if len(root.Sub) == 2 {
-				quant := root.Sub[1]
-				return repeat(atom, quant)
-			}
-			return atom
+	case syntax.KindAssertion:
+		return fromAssertion(root.Value[0])
 
-		case "Atom":
-			if root.Sub[0].Label == "." {
-				return fromPerl('.')
-			}
-			return fromSyntaxTree(root.Sub[0])
+	case syntax.KindAtom:
+		if root.Sub[0].Kind == syntax.KindDisjunction {
+			return cv.capture(root.Sub[0])
+		}
+		return cv.fromSyntaxTree(root.Sub[0])
 
-		case "Perl":
-			return fromPerl(root.Sub[0].Label[0])
+	case syntax.KindDot:
+		return cv.fromPerl('.')
 
-		case "Control":
-			return fromControl(root.Sub[0].Label[0])
+	case syntax.KindPerl:
+		return cv.fromPerl(root.Value[0])
 
-		case "HexSeq":
-			return fromHexSeq(root.Sub[0].Label)
+	case syntax.KindControl:
+		return fromControl(root.Value[0])
 
-		case "UniSeq":
-			return fromUniSeq(root.Sub[0].Label)
+	case syntax.KindHexSeq:
+		return fromHexSeq(root.Value)
 
-		case "Class":
-			return fromClass(root.Sub)
+	case syntax.KindUniSeq:
+		return cv.fromUniSeq(root.Value)
 
-		case "Literal":
-			return fromLiteral(root.Sub[0].Label)
+	case syntax.KindClass:
+		return cv.fromClass(root.Sub)
+
+	case syntax.KindLiteral:
+		return fromLiteral(root.Value)
 
-		}
 	}
 	panic(utils.ErrUnexpectedSymbol)
 }
 
-func FromInfixExp(infixExp string) Regexp {
+// fromChain converts the first children of root and of the nodes
+// nested as its second child in turn, as a disjunction nests its terms
+// and a term its factors. It loops rather than recurses along the
+// chain, which grows with the length of the pattern.
+func (cv *converter) fromChain(root *syntax.Node) []*Regexp {
+	var res []*Regexp
+	for node := root; ; node = node.Sub[1] {
+		res = append(res, cv.fromSyntaxTree(node.Sub[0]))
+		if len(node.Sub) < 2 {
+			return res
+		}
+	}
+}
+
+// fromInfixExp parses infixExp within lim, simplifies it
+// and compiles its program.
+func fromInfixExp(infixExp string, parse func(string, syntax.Limits) *syntax.Node, lim Limits) *Regexp {
+	re := parseInfixExp(infixExp, parse, lim)
+	re = simplifyRegexp(re)
+	re.prog = compileProgLimit(re, lim.MaxProg)
+	return re
+}
+
+// parseInfixExp converts infixExp into a tree as written.
+func parseInfixExp(infixExp string, parse func(string, syntax.Limits) *syntax.Node, lim Limits) *Regexp {
 	if infixExp == "" {
 		panic(utils.ErrEmptyRegexPattern)
 	}
 
-	re := fromSyntaxTree(syntax.ToSyntaxTree(infixExp))
-	return *simplifyRegexp(re)
+	cv := &converter{limits: lim}
+	return cv.fromSyntaxTree(parse(infixExp, syntax.Limits{MaxLen: lim.MaxLen, MaxDepth: lim.MaxDepth}))
+}
+
+func FromInfixExp(infixExp string) Regexp {
+	return *fromInfixExp(infixExp, syntax.ToSyntaxTreeLimits, DefaultLimits)
+}
+
+// Compile parses a regular expression and returns, if successful,
+// a Regexp that can be used to match against text.
+// The pattern must stay within DefaultLimits.
+func Compile(expr string) (*Regexp, error) {
+	return compile(expr, syntax.ToSyntaxTreeLimits, false, DefaultLimits)
+}
+
+// CompilePOSIX is like Compile but restricts the regular expression
+// to POSIX ERE syntax and switches to leftmost-longest semantics.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	return compile(expr, syntax.ToSyntaxTreePOSIXLimits, true, DefaultLimits)
+}
+
+// CompileLimits is like Compile but bounds the pattern by lim
+// instead of DefaultLimits.
+func CompileLimits(expr string, lim Limits) (*Regexp, error) {
+	return compile(expr, syntax.ToSyntaxTreeLimits, false, lim)
+}
+
+// Parse is like Compile but returns the tree as written, without
+// simplifying it. It is meant for tools inspecting the pattern itself,
+// such as the analysis package; the tree matches like the one Compile
+// returns, if more slowly.
+func Parse(expr string) (re *Regexp, err error) {
+	defer catch(expr, &err)
+
+	tree := acceptRegexp(parseInfixExp(expr, syntax.ToSyntaxTreeLimits, DefaultLimits))
+	tree.prog = compileProgLimit(tree, DefaultLimits.MaxProg)
+	return tree, nil
+}
+
+// catch turns a panicking ErrorCode into an error for expr.
+func catch(expr string, err *error) {
+	if r := recover(); r != nil {
+		code, ok := r.(utils.ErrorCode)
+		if !ok {
+			panic(r)
+		}
+		*err = &utils.Error{Code: code, Expr: expr}
+	}
+}
+
+func compile(expr string, parse func(string, syntax.Limits) *syntax.Node, longest bool, lim Limits) (re *Regexp, err error) {
+	defer catch(expr, &err)
+
+	re = fromInfixExp(expr, parse, lim)
+	if longest {
+		re.Longest()
+	}
+	return re, nil
 }
@@ -0,0 +1,237 @@
+package regexp
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// String returns the canonical pattern of re. Parsing the pattern
+// yields a tree matching the same strings as re. Subexpressions that
+// need grouping but are no capture group, which the parser never
+// produces, are printed as capture groups.
+func (re *Regexp) String() string {
+	var b strings.Builder
+	writeRegexp(&b, re)
+	return b.String()
+}
+
+// metaChars are the runes escaped outside of character classes.
+const metaChars = `^$\.*+?()[]{}|`
+
+// classMetaChars are the runes escaped inside of character classes.
+const classMetaChars = `\[]-^`
+
+func writeRegexp(b *strings.Builder, re *Regexp) {
+	switch re.Op {
+	case OpLiteral:
+		for _, r := range re.Sym {
+			writeRune(b, r, metaChars)
+		}
+	case OpCharClass:
+		writeClass(b, re.Sym)
+	case OpLineStart:
+		b.WriteByte('^')
+	case OpLineEnd:
+		b.WriteByte('$')
+	case OpWordBoundary:
+		b.WriteString(`\b`)
+	case OpNotWordBoundary:
+		b.WriteString(`\B`)
+	case OpCapture:
+		b.WriteByte('(')
+		writeRegexp(b, re.Sub[0])
+		b.WriteByte(')')
+	case OpConcat:
+		subs := slices.DeleteFunc(slices.Clone(re.Sub), func(sub *Regexp) bool {
+			return sub.Op == OpAccept
+		})
+		for _, sub := range subs {
+			if sub.Op == OpAlternate && len(subs) > 1 {
+				writeGroup(b, sub)
+			} else {
+				writeRegexp(b, sub)
+			}
+		}
+	case OpAlternate:
+		for j, sub := range re.Sub {
+			if j > 0 {
+				b.WriteByte('|')
+			}
+			writeRegexp(b, sub)
+		}
+	case OpRepeat:
+		switch sub := re.Sub[0]; {
+		case sub.Op == OpLiteral && len(sub.Sym) == 1, sub.Op == OpCharClass, sub.Op == OpCapture:
+			writeRegexp(b, sub)
+		default:
+			writeGroup(b, sub)
+		}
+		switch {
+		case re.Min == 0 && re.Max == -1:
+			b.WriteByte('*')
+		case re.Min == 1 && re.Max == -1:
+			b.WriteByte('+')
+		case re.Min == 0 && re.Max == 1:
+			b.WriteByte('?')
+		case re.Max == -1:
+			fmt.Fprintf(b, "{%d,}", re.Min)
+		case re.Min == re.Max:
+			fmt.Fprintf(b, "{%d}", re.Min)
+		default:
+			fmt.Fprintf(b, "{%d,%d}", re.Min, re.Max)
+		}
+	}
+}
+
+func writeGroup(b *strings.Builder, re *Regexp) {
+	b.WriteByte('(')
+	writeRegexp(b, re)
+	b.WriteByte(')')
+}
+
+// writeRune writes r, escaping it if it is one of meta
+// or not printable.
+func writeRune(b *strings.Builder, r rune, meta string) {
+	switch {
+	case strings.ContainsRune(meta, r):
+		b.WriteByte('\\')
+		b.WriteRune(r)
+	case r == '\t':
+		b.WriteString(`\t`)
+	case r == '\n':
+		b.WriteString(`\n`)
+	case r == '\v':
+		b.WriteString(`\v`)
+	case r == '\f':
+		b.WriteString(`\f`)
+	case r == '\r':
+		b.WriteString(`\r`)
+	case !unicode.IsPrint(r):
+		fmt.Fprintf(b, `\x{%x}`, r)
+	default:
+		b.WriteRune(r)
+	}
+}
+
+// writeClass writes rr by name if it is a Perl or Unicode class,
+// as a single rune if it holds one, and as a bracketed list of
+// ranges otherwise, negated if that is shorter. The list may start
+// with a Perl or Unicode class if that leaves fewer ranges, as in
+// [^\Wb] for the word characters other than b.
+func writeClass(b *strings.Builder, rr RuneRange) {
+	if name, ok := className(rr); ok {
+		b.WriteString(name)
+		return
+	}
+	if len(rr) == 2 && rr[0] == rr[1] {
+		writeRune(b, rr[0], metaChars)
+		return
+	}
+	neg := negateClass(append(RuneRange(nil), rr...))
+	negated, name := false, ""
+	if len(rr) == 0 || rr[0] == 0 && rr[len(rr)-1] == unicode.MaxRune {
+		negated = true
+	}
+	rest := rr
+	if negated {
+		rest = neg
+	}
+	var buf RuneRange
+	for _, c := range namedClasses() {
+		for _, n := range []bool{false, true} {
+			set := rr
+			if n {
+				set = neg
+			}
+			if !subClass(c.rr, set) {
+				continue
+			}
+			var ok bool
+			if buf, ok = subtractClass(buf[:0], set, c.rr, len(rest)); ok {
+				negated, name, rest = n, c.name, slices.Clone(buf)
+			}
+		}
+	}
+	b.WriteByte('[')
+	if negated {
+		b.WriteByte('^')
+	}
+	b.WriteString(name)
+	rr = rest
+	for i := 0; i < len(rr); i += 2 {
+		lo, hi := rr[i], rr[i+1]
+		writeRune(b, lo, classMetaChars)
+		if hi > lo+1 {
+			b.WriteByte('-')
+		}
+		if hi > lo {
+			writeRune(b, hi, classMetaChars)
+		}
+	}
+	b.WriteByte(']')
+}
+
+// A namedClass is a Perl or Unicode class and its escape.
+type namedClass struct {
+	name string
+	rr   RuneRange
+}
+
+// namedClasses returns the Perl and Unicode classes that may appear
+// within brackets, with their negations.
+var namedClasses = sync.OnceValue(func() []namedClass {
+	var classes []namedClass
+	for _, c := range "dDsSwW" {
+		classes = append(classes, namedClass{`\` + string(c), PerlClass[uint8(c)]})
+	}
+	names := make([]string, 0, len(UniClass))
+	for name := range UniClass {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if rr := UniClass[name]; len(rr) > 0 {
+			classes = append(classes,
+				namedClass{`\p{` + name + `}`, rr},
+				namedClass{`\P{` + name + `}`, negateClass(append(RuneRange(nil), rr...))})
+		}
+	}
+	return classes
+})
+
+// className returns the escape naming the class rr, if any.
+func className(rr RuneRange) (string, bool) {
+	if slices.Equal(rr, PerlClass['.']) {
+		return ".", true
+	}
+	for _, c := range "dDsSwW" {
+		if slices.Equal(rr, PerlClass[uint8(c)]) {
+			return `\` + string(c), true
+		}
+	}
+	if len(rr) == 0 {
+		return "", false
+	}
+	names := make([]string, 0, len(UniClass))
+	for name := range UniClass {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	neg := negateClass(append(RuneRange(nil), rr...))
+	for _, name := range names {
+		if len(UniClass[name]) == 0 {
+			continue
+		}
+		if slices.Equal(rr, UniClass[name]) {
+			return `\p{` + name + `}`, true
+		}
+		if slices.Equal(neg, UniClass[name]) {
+			return `\P{` + name + `}`, true
+		}
+	}
+	return "", false
+}
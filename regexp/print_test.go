@@ -0,0 +1,95 @@
+package regexp
+
+import (
+	"testing"
+)
+
+var printTests = []struct {
+	pattern, want string
+}{
+	{"abc", "abc"},
+	{"a|b|cd", "[ab]|cd"},
+	{"(a|b)c", "([ab])c"},
+	{"x*y+z?", "x*y+z?"},
+	{"x{2}y{2,}z{2,5}", "x{2}y{2,}z{2,5}"},
+	{"x{0,}y{1,}z{0,1}", "x*y+z?"},
+	{"^a$\\bb\\B", "^a$\\bb\\B"},
+	{"[a-z]", "[a-z]"},
+	{"[abcx-z]", "[a-cx-z]"},
+	{"[ab]", "[ab]"},
+	{"[a]", "a"},
+	{"[^a-z]", "[^a-z]"},
+	{"[^\\n]", "[^\\n]"},
+	{"[\\d]", "\\d"},
+	{"[^\\d]", "\\D"},
+	{"\\w+@\\s", "\\w+@\\s"},
+	{".", "."},
+	{"\\p{Lu}", "\\p{Lu}"},
+	{"\\P{Lu}", "\\P{Lu}"},
+	{"[\\p{Nd}]", "\\p{Nd}"},
+	{"[^\\Wb]", "[^\\Wb]"},
+	{"[\\w\\-]", "[\\w\\-]"},
+	{"[0-9a]", "[0-9a]"},
+	{"\\.\\*\\+\\?\\(\\)\\[\\]\\{\\}\\|\\^\\$\\\\", "\\.\\*\\+\\?\\(\\)\\[\\]\\{\\}\\|\\^\\$\\\\"},
+	{"[\\]\\-\\^\\\\]", "[\\-\\\\-\\^]"},
+	{"[.*+]", "[*+.]"},
+	{"\\t\\n\\x{1}", "\\t\\n\\x{1}"},
+	{"\\x{41}", "A"},
+	{"((a)(b))*", "((a)(b))*"},
+	{"a{0}", "a{0}"},
+	{"a{0}|b", "a{0}|b"},
+	{"(a{0})b", "(a{0})b"},
+	{"x(a{0})?y", "x(a{0})?y"},
+}
+
+func TestString(t *testing.T) {
+	for _, test := range printTests {
+		re := FromInfixExp(test.pattern)
+		got := re.String()
+		if got != test.want {
+			t.Errorf("error: %q\ngot: %q\nwant: %q", test.pattern, got, test.want)
+			continue
+		}
+		// The canonical pattern prints to itself.
+		re2 := FromInfixExp(got)
+		if again := re2.String(); again != got {
+			t.Errorf("error: round trip %q\ngot: %q\nwant: %q", test.pattern, again, got)
+		}
+		if re.NumSubexp() != re2.NumSubexp() {
+			t.Errorf("error: groups %q\ngot: %d\nwant: %d", test.pattern, re2.NumSubexp(), re.NumSubexp())
+		}
+	}
+}
+
+func TestStringEquivalent(t *testing.T) {
+	inputs := []string{"", "abc", "a.b*c", "[x]", "ABC def", "12\t3\n", "a\\b^$", "Ünïcode ÄÖ"}
+	for _, test := range append(matchTests, struct{ pattern, input string }{"[\\]\\-\\^\\\\]+", ""}) {
+		re := FromInfixExp(test.pattern)
+		re2 := FromInfixExp(re.String())
+		for _, input := range append(inputs, test.input) {
+			got := re2.FindAllStringIndex(input, -1, false)
+			want := re.FindAllStringIndex(input, -1, false)
+			if len(got) != len(want) {
+				t.Errorf("error: %q -> %q on %q\ngot: %v\nwant: %v", test.pattern, re.String(), input, got, want)
+				continue
+			}
+			for j := range got {
+				if !intsEqual(got[j], want[j]) {
+					t.Errorf("error: %q -> %q on %q\ngot: %v\nwant: %v", test.pattern, re.String(), input, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestStringEmptyClass(t *testing.T) {
+	re := &Regexp{Op: OpCharClass}
+	want := "[^\\x{0}-\\x{10ffff}]"
+	if got := re.String(); got != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, want)
+	}
+	re2 := FromInfixExp(want)
+	if re2.MatchString("a", false) {
+		t.Errorf("error: empty class matches")
+	}
+}
@@ -0,0 +1,335 @@
+package regexp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tautastic/rex/utils"
+)
+
+// An InstOp is a single instruction opcode of a compiled program.
+type InstOp uint8
+
+const (
+	InstAlt        InstOp = iota // continue at Out, or at Arg with lower priority
+	InstCapture                  // record the position in capture slot Arg
+	InstEmptyWidth               // assert the zero-width conditions in Arg
+	InstMatch                    // report a match
+	InstFail                     // stop the thread
+	InstNop                      // continue at Out
+	InstRune                     // consume a rune in Rune
+)
+
+var instOpNames = []string{
+	"InstAlt",
+	"InstCapture",
+	"InstEmptyWidth",
+	"InstMatch",
+	"InstFail",
+	"InstNop",
+	"InstRune",
+}
+
+func (i InstOp) String() string {
+	if int(i) >= len(instOpNames) {
+		return ""
+	}
+	return instOpNames[i]
+}
+
+// An EmptyOp specifies a kind or mixture of zero-width assertions.
+type EmptyOp uint8
+
+const (
+	EmptyLineStart EmptyOp = 1 << iota
+	EmptyLineEnd
+	EmptyWordBoundary
+	EmptyNoWordBoundary
+)
+
+// emptyOpContext returns the zero-width assertions satisfied
+// between the runes r0 and r1. Passing endOfText for r0 denotes
+// the beginning of the input, for r1 the end of the input.
+func emptyOpContext(r0, r1 rune) EmptyOp {
+	var op EmptyOp = EmptyNoWordBoundary
+	if r0 == endOfText || r0 == '\n' {
+		op |= EmptyLineStart
+	}
+	if r1 == endOfText || r1 == '\n' {
+		op |= EmptyLineEnd
+	}
+	if isWordChar(r0) != isWordChar(r1) {
+		op ^= EmptyWordBoundary | EmptyNoWordBoundary
+	}
+	return op
+}
+
+// An Inst is a single instruction in a compiled program.
+type Inst struct {
+	Op   InstOp
+	Out  uint32
+	Arg  uint32
+	Rune RuneRange
+}
+
+// matchRune reports whether the instruction matches (and consumes) r.
+// It should only be called when i.Op == InstRune. With fold set,
+// the case of r is ignored.
+func (i *Inst) matchRune(r rune, fold bool) bool {
+	return r != endOfText && i.Rune.matchRunePos(r, fold) != noMatch
+}
+
+// A Prog is a compiled regular expression program.
+type Prog struct {
+	Inst   []Inst
+	Start  int // index of the start instruction
+	NumCap int // number of capture slots, two per group including the whole match
+
+	nodes []*Regexp // the subexpression each instruction was compiled from
+}
+
+func (p *Prog) String() string {
+	var b strings.Builder
+	for pc := range p.Inst {
+		i := &p.Inst[pc]
+		mark := " "
+		if pc == p.Start {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%3d%s %v", pc, mark, i.Op)
+		switch i.Op {
+		case InstAlt:
+			fmt.Fprintf(&b, " -> %d, %d", i.Out, i.Arg)
+		case InstCapture, InstEmptyWidth:
+			fmt.Fprintf(&b, " %d -> %d", i.Arg, i.Out)
+		case InstRune:
+			fmt.Fprintf(&b, " %q -> %d", string(i.Rune), i.Out)
+		case InstNop:
+			fmt.Fprintf(&b, " -> %d", i.Out)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// A patchList is a list of instruction holes to be filled in later.
+// A hole refers to the Out field of instruction n>>1 if n&1 == 0
+// and to its Arg field otherwise.
+type patchList []uint32
+
+func (l patchList) patch(p *Prog, val uint32) {
+	for _, n := range l {
+		i := &p.Inst[n>>1]
+		if n&1 == 0 {
+			i.Out = val
+		} else {
+			i.Arg = val
+		}
+	}
+}
+
+// A frag is a compiled fragment with an entry instruction
+// and the holes leading out of it.
+type frag struct {
+	i        uint32
+	out      patchList
+	nullable bool // whether the fragment can match the empty string
+}
+
+type compiler struct {
+	p        *Prog
+	reversed bool    // compile the program matching the reversed input
+	maxInst  int     // largest program allowed, 0 if unbounded
+	node     *Regexp // the subexpression being compiled
+}
+
+// compileProg compiles the syntax tree re into a program.
+func compileProg(re *Regexp) *Prog {
+	return compileProgLimit(re, 0)
+}
+
+// compileProgLimit is like compileProg but panics with
+// ErrProgramTooLarge once the program grows beyond maxInst
+// instructions, unless maxInst is 0.
+func compileProgLimit(re *Regexp, maxInst int) *Prog {
+	c := compiler{p: &Prog{NumCap: 2 * (re.NumSubexp() + 1)}, maxInst: maxInst}
+	c.inst(InstFail)
+	f := c.cat(c.cat(c.cap(0), c.compile(re)), c.cap(1))
+	f.out.patch(c.p, c.inst(InstMatch).i)
+	c.p.Start = int(f.i)
+	return c.p
+}
+
+func (c *compiler) inst(op InstOp) frag {
+	if c.maxInst > 0 && len(c.p.Inst) >= c.maxInst {
+		panic(utils.ErrProgramTooLarge)
+	}
+	f := frag{i: uint32(len(c.p.Inst)), nullable: true}
+	c.p.Inst = append(c.p.Inst, Inst{Op: op})
+	c.p.nodes = append(c.p.nodes, c.node)
+	return f
+}
+
+func (c *compiler) nop() frag {
+	f := c.inst(InstNop)
+	f.out = patchList{f.i << 1}
+	return f
+}
+
+func (c *compiler) rune(rr RuneRange) frag {
+	f := c.inst(InstRune)
+	f.nullable = false
+	c.p.Inst[f.i].Rune = rr
+	f.out = patchList{f.i << 1}
+	return f
+}
+
+func (c *compiler) empty(op EmptyOp) frag {
+	f := c.inst(InstEmptyWidth)
+	c.p.Inst[f.i].Arg = uint32(op)
+	f.out = patchList{f.i << 1}
+	return f
+}
+
+func (c *compiler) cap(slot uint32) frag {
+	f := c.inst(InstCapture)
+	c.p.Inst[f.i].Arg = slot
+	f.out = patchList{f.i << 1}
+	return f
+}
+
+func (c *compiler) cat(f1, f2 frag) frag {
+	f1.out.patch(c.p, f2.i)
+	return frag{f1.i, f2.out, f1.nullable && f2.nullable}
+}
+
+func (c *compiler) alt(f1, f2 frag) frag {
+	f := c.inst(InstAlt)
+	i := &c.p.Inst[f.i]
+	i.Out = f1.i
+	i.Arg = f2.i
+	f.out = append(append(patchList{}, f1.out...), f2.out...)
+	f.nullable = f1.nullable || f2.nullable
+	return f
+}
+
+// quest returns the fragment for f1?.
+func (c *compiler) quest(f1 frag) frag {
+	f := c.inst(InstAlt)
+	c.p.Inst[f.i].Out = f1.i
+	f.out = append(patchList{f.i<<1 | 1}, f1.out...)
+	return f
+}
+
+// star returns the fragment for f1*.
+func (c *compiler) star(f1 frag) frag {
+	if f1.nullable {
+		// Use (f1+)? to avoid an empty loop through the Alt.
+		return c.quest(c.plus(f1))
+	}
+	f := c.inst(InstAlt)
+	c.p.Inst[f.i].Out = f1.i
+	f.out = patchList{f.i<<1 | 1}
+	f1.out.patch(c.p, f.i)
+	return f
+}
+
+// plus returns the fragment for f1+.
+func (c *compiler) plus(f1 frag) frag {
+	f := c.inst(InstAlt)
+	c.p.Inst[f.i].Out = f1.i
+	f.out = patchList{f.i<<1 | 1}
+	f1.out.patch(c.p, f.i)
+	return frag{f1.i, f.out, f1.nullable}
+}
+
+func (c *compiler) compile(re *Regexp) frag {
+	outer := c.node
+	c.node = re
+	defer func() { c.node = outer }()
+	switch re.Op {
+	case OpLiteral:
+		f := c.nop()
+		for j := range re.Sym {
+			r := re.Sym[j]
+			if c.reversed {
+				r = re.Sym[len(re.Sym)-1-j]
+			}
+			f = c.cat(f, c.rune(RuneRange{r}))
+		}
+		return f
+	case OpCharClass:
+		return c.rune(re.Sym)
+	case OpLineStart:
+		if c.reversed {
+			return c.empty(EmptyLineEnd)
+		}
+		return c.empty(EmptyLineStart)
+	case OpLineEnd:
+		if c.reversed {
+			return c.empty(EmptyLineStart)
+		}
+		return c.empty(EmptyLineEnd)
+	case OpWordBoundary:
+		return c.empty(EmptyWordBoundary)
+	case OpNotWordBoundary:
+		return c.empty(EmptyNoWordBoundary)
+	case OpCapture:
+		if c.reversed {
+			return c.compile(re.Sub[0])
+		}
+		f := c.cat(c.cap(uint32(2*re.Cap)), c.compile(re.Sub[0]))
+		return c.cat(f, c.cap(uint32(2*re.Cap+1)))
+	case OpConcat:
+		f := c.nop()
+		for j := range re.Sub {
+			sub := re.Sub[j]
+			if c.reversed {
+				sub = re.Sub[len(re.Sub)-1-j]
+			}
+			if sub.Op != OpAccept {
+				f = c.cat(f, c.compile(sub))
+			}
+		}
+		return f
+	case OpAlternate:
+		f := c.compile(re.Sub[len(re.Sub)-1])
+		for j := len(re.Sub) - 2; j >= 0; j-- {
+			f = c.alt(c.compile(re.Sub[j]), f)
+		}
+		return f
+	case OpRepeat:
+		return c.repeat(re)
+	case OpAccept:
+		return c.nop()
+	}
+	panic(fmt.Sprintf("regexp: unexpected op %d in compile", re.Op))
+}
+
+// repeat expands x{n,m} into n copies of x followed by
+// m-n nested optional copies, and x{n,} into n-1 copies of x
+// followed by x+.
+func (c *compiler) repeat(re *Regexp) frag {
+	sub := re.Sub[0]
+	f := c.nop()
+	if re.Max == -1 {
+		if re.Min == 0 {
+			return c.star(c.compile(sub))
+		}
+		for j := 1; j < re.Min; j++ {
+			f = c.cat(f, c.compile(sub))
+		}
+		return c.cat(f, c.plus(c.compile(sub)))
+	}
+	for j := 0; j < re.Min; j++ {
+		f = c.cat(f, c.compile(sub))
+	}
+	if re.Max > re.Min {
+		opt := c.quest(c.compile(sub))
+		for j := re.Min + 1; j < re.Max; j++ {
+			opt = c.quest(c.cat(c.compile(sub), opt))
+		}
+		f = c.cat(f, opt)
+	}
+	return f
+}
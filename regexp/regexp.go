@@ -1,6 +1,10 @@
 package regexp
 
-import "unicode"
+import (
+	"sync"
+	"time"
+	"unicode"
+)
 
 // An Op is a single regular expression operator.
 type Op uint8
@@ -8,7 +12,7 @@ type Op uint8
 const noMatch = -1
 
 const (
-	OpLiteral         Op = 1 + iota // matches a single rune
+	OpLiteral         Op = 1 + iota // matches the runes of Sym in sequence
 	OpCharClass                     // matches Runes interpreted as range pair list
 	OpRepeat                        // matches Sub[0] at least Min times, at most Max (Max == -1 is no limit)
 	OpConcat                        // matches concatenation of Subs
@@ -17,19 +21,107 @@ const (
 	OpLineEnd                       // asserts position at the end of a line
 	OpWordBoundary                  // asserts position at a word boundary
 	OpNotWordBoundary               // asserts position where \b does not match
+	OpCapture                       // capturing subexpression with index Cap
 	OpAccept
 )
 
+var opNames = []string{
+	OpLiteral:         "Literal",
+	OpCharClass:       "CharClass",
+	OpRepeat:          "Repeat",
+	OpConcat:          "Concat",
+	OpAlternate:       "Alternate",
+	OpLineStart:       "LineStart",
+	OpLineEnd:         "LineEnd",
+	OpWordBoundary:    "WordBoundary",
+	OpNotWordBoundary: "NotWordBoundary",
+	OpCapture:         "Capture",
+	OpAccept:          "Accept",
+}
+
+func (op Op) String() string {
+	if int(op) >= len(opNames) {
+		return ""
+	}
+	return opNames[op]
+}
+
 // A Regexp is a node in a regular expression syntax tree.
+// A compiled Regexp is safe for concurrent use by multiple goroutines,
+// except for the methods Longest, MaxSteps and Timeout changing it.
 type Regexp struct {
 	Op  Op
 	Min int
 	Max int
+	Cap int
 	Sym RuneRange
 	Sub []*Regexp
+
+	longest bool // leftmost-longest instead of leftmost-first
+
+	// The caches below are built on first use, each exactly once, so
+	// that a Regexp can be used from several goroutines at once.
+	prog     *Prog // compiled program
+	progOnce sync.Once
+	dfa      *dfaPool // lazy DFAs for matches without captures
+	dfaOnce  sync.Once
+
+	prefilter     *prefilter // literal prefilter
+	prefilterOnce sync.Once
+	ac            *AhoCorasick // automaton for large literal alternations, if any
+	acOnce        sync.Once
+
+	onePass     *onePass // one-pass form of prog, if any
+	onePassOnce sync.Once
+
+	rdfa        *dfaPool // lazy DFAs of the reversed program
+	endAnchored bool     // matches end at a line end and never span lines
+	rdfaOnce    sync.Once
+
+	maxSteps int           // step limit of bounded matches, 0 if none
+	timeout  time.Duration // time limit of bounded matches, 0 if none
+}
+
+// Longest makes future searches prefer leftmost-longest matches.
+// That is, when matching against text, the regexp returns a match that
+// begins as early as possible in the input (leftmost), and among those
+// it chooses a match that is as long as possible.
+// Longest must not be called while re is matching.
+func (re *Regexp) Longest() {
+	re.longest = true
+	re.dfa, re.dfaOnce = nil, sync.Once{}
+	re.ac, re.acOnce = nil, sync.Once{}
+	for _, sub := range re.Sub {
+		sub.Longest()
+	}
 }
 
-var iFlag bool
+// Prog returns the compiled program of re.
+func (re *Regexp) Prog() *Prog {
+	return re.getProg()
+}
+
+// getProg returns the compiled program of re, compiling it if necessary.
+func (re *Regexp) getProg() *Prog {
+	re.progOnce.Do(func() {
+		if re.prog == nil {
+			re.prog = compileProg(re)
+		}
+	})
+	return re.prog
+}
+
+// NumSubexp returns the number of parenthesized subexpressions in re.
+func (re *Regexp) NumSubexp() int {
+	n := 0
+	if re.Op == OpCapture {
+		n = re.Cap
+	}
+	for _, sub := range re.Sub {
+		n = max(n, sub.NumSubexp())
+	}
+	return n
+}
 
 // matchRune checks whether the expression matches (and consumes) r.
 func (re *Regexp) matchRune(r rune) bool {
@@ -41,39 +133,55 @@ func isWordChar(r rune) bool {
 	if r == endOfText {
 		return false
 	}
-	re := &Regexp{Op: OpCharClass, Sym: PerlClass['w']}
-	return re.matchRunePos(r) != noMatch
+	return PerlClass['w'].matchRunePos(r, false) != noMatch
 }
 
 // matchRunePos checks whether the expression matches (and consumes) r.
 // If so, matchRunePos returns the index of the matching rune pair.
 // If not, matchRunePos returns -1.
 func (re *Regexp) matchRunePos(ch rune) int {
-	switch len(re.Sym) {
+	return re.Sym.matchRunePos(ch, false)
+}
+
+// matchRunePos checks whether ch is in the range pair list rr.
+// If so, matchRunePos returns the index of the matching rune pair.
+// If not, matchRunePos returns -1. With fold set, ch also matches
+// if any rune of its case folding orbit is in rr.
+func (rr RuneRange) matchRunePos(ch rune, fold bool) int {
+	j := rr.find(ch)
+	if j != noMatch || !fold {
+		return j
+	}
+	for f := unicode.SimpleFold(ch); f != ch; f = unicode.SimpleFold(f) {
+		if j := rr.find(f); j != noMatch {
+			return j
+		}
+	}
+	return noMatch
+}
+
+// find is matchRunePos without case folding.
+func (rr RuneRange) find(ch rune) int {
+	switch len(rr) {
 	case 0:
 		return noMatch
 	case 1:
-		if ch == re.Sym[0] || iFlag &&
-			unicode.SimpleFold(ch) == re.Sym[0] {
+		if ch == rr[0] {
 			return 0
 		}
 		return noMatch
 	case 2:
-		if (re.Sym[0] <= ch && ch <= re.Sym[1]) || iFlag &&
-			re.Sym[0] <= unicode.SimpleFold(ch) &&
-			unicode.SimpleFold(ch) <= re.Sym[1] {
+		if rr[0] <= ch && ch <= rr[1] {
 			return 0
 		}
 		return noMatch
 	case 4, 6, 8:
 		// Linear search for a few pairs.
-		for j := 0; j < len(re.Sym); j += 2 {
-			if ch < re.Sym[j] || iFlag &&
-				unicode.SimpleFold(ch) < re.Sym[j] {
+		for j := 0; j < len(rr); j += 2 {
+			if ch < rr[j] {
 				return noMatch
 			}
-			if ch <= re.Sym[j+1] || iFlag &&
-				unicode.SimpleFold(ch) <= re.Sym[j+1] {
+			if ch <= rr[j+1] {
 				return j / 2
 			}
 		}
@@ -81,14 +189,11 @@ func (re *Regexp) matchRunePos(ch rune) int {
 	}
 	// Otherwise binary search.
 	lo := 0
-	hi := len(re.Sym) / 2
+	hi := len(rr) / 2
 	for lo < hi {
 		m := lo + (hi-lo)/2
-		c := re.Sym[2*m]
-		if c <= ch || iFlag && c <= unicode.SimpleFold(ch) {
-			if c <= ch && ch <= re.Sym[2*m+1] ||
-				c <= unicode.SimpleFold(ch) &&
-					unicode.SimpleFold(ch) <= re.Sym[2*m+1] {
+		if c := rr[2*m]; c <= ch {
+			if ch <= rr[2*m+1] {
 				return m
 			}
 			lo = m + 1
@@ -99,20 +204,24 @@ func (re *Regexp) matchRunePos(ch rune) int {
 	return noMatch
 }
 
-func (re *Regexp) allMatches(str string, n int, deliver func([]int)) {
+// allMatches calls deliver with the positions of at most n successive
+// non-overlapping matches in str, each followed by the positions of the
+// first ncap capture groups. It stops early when deliver returns false.
+func (re *Regexp) allMatches(str string, n int, ncap int, c call, deliver func([]int) bool) {
 	end := len(str)
+	if !re.getPrefilter().possible(str, 0, c.fold) {
+		return
+	}
 
-	for pos, i := 0, 0; i < n && pos <= end; {
-		matches := re.doOnePass(str, pos, nil)
-		if len(matches) == 0 {
-			// No match found, move on.
-			pos++
-			continue
+	for pos, i := 0, 0; i < n && pos <= end; i++ {
+		matches := re.doExecute(str, pos, ncap, c)
+		if matches == nil {
+			return
 		}
 		if matches[0] == matches[1] {
-			_, width := step(str, pos)
+			_, width := step(str, matches[1])
 			if width > 0 {
-				pos += width
+				pos = matches[1] + width
 			} else {
 				pos = end + 1
 			}
@@ -120,20 +229,18 @@ func (re *Regexp) allMatches(str string, n int, deliver func([]int)) {
 			pos = matches[1]
 		}
 
-		deliver(matches)
-		i++
+		if !deliver(matches) {
+			return
+		}
 	}
 }
 
 func (re *Regexp) MatchString(str string, i bool) bool {
-	iFlag = i
-	return re.doMatch(str)
+	return re.doMatch(str, call{fold: i})
 }
 
 func (re *Regexp) FindString(str string, i bool) string {
-	iFlag = i
-	var dstCap [2]int
-	a := re.doOnePass(str, 0, dstCap[:0])
+	a := re.doExecute(str, 0, 0, call{fold: i})
 	if a == nil {
 		return ""
 	}
@@ -141,8 +248,7 @@ func (re *Regexp) FindString(str string, i bool) string {
 }
 
 func (re *Regexp) FindStringIndex(str string, i bool) []int {
-	iFlag = i
-	a := re.doOnePass(str, 0, nil)
+	a := re.doExecute(str, 0, 0, call{fold: i})
 	if a == nil {
 		return nil
 	}
@@ -150,32 +256,37 @@ func (re *Regexp) FindStringIndex(str string, i bool) []int {
 }
 
 func (re *Regexp) FindAllString(str string, n int, i bool) []string {
-	iFlag = i
+	return re.findAllString(str, n, call{fold: i})
+}
+
+// findAllString is FindAllString run as the call c.
+func (re *Regexp) findAllString(str string, n int, c call) []string {
 	if n < 0 {
 		n = len(str) + 1
 	}
 	var result []string
-	re.allMatches(str, n, func(match []int) {
+	re.allMatches(str, n, 0, c, func(match []int) bool {
 		if result == nil {
 			result = make([]string, 0, 10)
 		}
 		result = append(result, str[match[0]:match[1]])
+		return true
 	})
 	return result
 }
 
 func (re *Regexp) FindAllStringIndex(str string, n int, i bool) [][]int {
-	iFlag = i
 	if n < 0 {
 		n = len(str) + 1
 	}
 	var result [][]int
-	re.allMatches(str, n,
-		func(match []int) {
+	re.allMatches(str, n, 0, call{fold: i},
+		func(match []int) bool {
 			if result == nil {
 				result = make([][]int, 0, 10)
 			}
 			result = append(result, match[0:2])
+			return true
 		})
 	return result
 }
@@ -1,6 +1,13 @@
 package regexp
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tautastic/rex/utils"
+)
 
 func benchmarkRegexpMatch(b *testing.B, re *Regexp, ch rune, times int) {
 	for i := 0; i < b.N; i++ {
@@ -203,3 +210,155 @@ func TestMatchClass(t *testing.T) {
 		}
 	}
 }
+
+func TestLongest(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+		first, longest string
+	}{
+		{"a|ab", "ab", "a", "ab"},
+		{"ab|a", "ab", "ab", "ab"},
+		{"x(a|ab|abc)", "xabc", "xa", "xabc"},
+		{"(a|ab)(c|bcd)", "abcd", "abcd", "abcd"},
+	} {
+		re, err := Compile(test.pattern)
+		if err != nil {
+			t.Fatalf("error: %q: %v", test.pattern, err)
+		}
+		if got := re.FindString(test.input, false); got != test.first {
+			t.Errorf("error: %q on %q\ngot: %q\nwant: %q",
+				test.pattern, test.input, got, test.first)
+		}
+		re.Longest()
+		if got := re.FindString(test.input, false); got != test.longest {
+			t.Errorf("error: longest %q on %q\ngot: %q\nwant: %q",
+				test.pattern, test.input, got, test.longest)
+		}
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		limits  Limits
+		code    utils.ErrorCode
+	}{
+		{"a{1000}", DefaultLimits, ""},
+		{"a{1001}", DefaultLimits, utils.ErrRepeatTooLarge},
+		{"a{2,1001}", DefaultLimits, utils.ErrRepeatTooLarge},
+		{"(a{1000}){1000}", DefaultLimits, utils.ErrProgramTooLarge},
+		{"(a{100}){100}", DefaultLimits, ""},
+		{"((a))", Limits{MaxDepth: 2}, ""},
+		{"((a)*)", Limits{MaxDepth: 2}, utils.ErrNestingTooDeep},
+		{"(((a)))", Limits{MaxDepth: 2}, utils.ErrNestingTooDeep},
+		{"abcdefgh", Limits{MaxDepth: 1}, ""},
+		{"[a-z]\\d.", Limits{MaxClasses: 3}, ""},
+		{"[a-z]\\d.\\w", Limits{MaxClasses: 3}, utils.ErrTooManyClasses},
+		{"abc", Limits{MaxProg: 3}, utils.ErrProgramTooLarge},
+		{"abc", Limits{MaxLen: 2}, utils.ErrPatternTooLong},
+		{"a|", DefaultLimits, utils.ErrUnexpectedSymbol},
+		{"a(", DefaultLimits, utils.ErrUnexpectedSymbol},
+		{"[a", DefaultLimits, utils.ErrUnexpectedSymbol},
+		{"a\\x{", DefaultLimits, utils.ErrUnexpectedSymbol},
+		{strings.Repeat("(", 1<<16), DefaultLimits, utils.ErrNestingTooDeep},
+		{strings.Repeat("a", 1<<18+1), DefaultLimits, utils.ErrPatternTooLong},
+	} {
+		_, err := CompileLimits(test.pattern, test.limits)
+		var want error
+		if test.code != "" {
+			want = &utils.Error{Code: test.code, Expr: test.pattern}
+		}
+		if fmt.Sprint(err) != fmt.Sprint(want) {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.pattern, err, want)
+		}
+	}
+}
+
+// TestConcurrentCompile compiles patterns with different capture
+// counts, syntaxes and limits at once, which share no parser state.
+func TestConcurrentCompile(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				n := (g+j)%4 + 1
+				re, err := Compile(strings.Repeat("(a)", n))
+				if err != nil {
+					t.Errorf("error: %d groups\ngot: %v\nwant: no error", n, err)
+				} else if re.NumSubexp() != n {
+					t.Errorf("error: %d groups\ngot: %d\nwant: %d", n, re.NumSubexp(), n)
+				}
+				if _, err := CompilePOSIX(`\d`); err == nil {
+					t.Errorf("error: %q accepted by CompilePOSIX", `\d`)
+				}
+				if _, err := CompileLimits("((a))", Limits{MaxDepth: 2, MaxClasses: 1}); err != nil {
+					t.Errorf("error: %q\ngot: %v\nwant: no error", "((a))", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompilePOSIX(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		err     error
+	}{
+		{"a|ab", nil},
+		{"[a-z]+(x|y)?", nil},
+		{"\\d+", &utils.Error{Code: utils.ErrInvalidEscape, Expr: "\\d+"}},
+		{"[\\w]", &utils.Error{Code: utils.ErrInvalidEscape, Expr: "[\\w]"}},
+		{"\\p{Lu}", &utils.Error{Code: utils.ErrInvalidEscape, Expr: "\\p{Lu}"}},
+		{"a\\b", &utils.Error{Code: utils.ErrInvalidEscape, Expr: "a\\b"}},
+		{"(a", &utils.Error{Code: utils.ErrUnexpectedSymbol, Expr: "(a"}},
+	} {
+		_, err := CompilePOSIX(test.pattern)
+		if fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.pattern, err, test.err)
+		}
+	}
+
+	re, _ := CompilePOSIX("a|ab")
+	if got := re.FindString("ab", false); got != "ab" {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, "ab")
+	}
+}
+
+// TestConcurrentMatch shares each Regexp between goroutines, which
+// build its caches and DFAs while they match.
+func TestConcurrentMatch(t *testing.T) {
+	input := strings.Repeat("x 12.log\nab aab FOO bar7 qux\n", 8)
+	for _, pattern := range []string{
+		`\d+[.]log$`,
+		"a+b",
+		`\b\w+\d\b`,
+		"foo|bar|baz|qux|quux|corge|grault|garply",
+		"(a|b)*b",
+	} {
+		re, err := Compile(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := FromInfixExp(pattern)
+		want := fmt.Sprint(ref.FindAllString(input, -1, false))
+		var wg sync.WaitGroup
+		for range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					if !re.MatchString(input, false) {
+						t.Errorf("error: %q does not match", pattern)
+					}
+					if got := fmt.Sprint(re.FindAllString(input, -1, false)); got != want {
+						t.Errorf("error: %q\ngot: %s\nwant: %s", pattern, got, want)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
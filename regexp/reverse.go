@@ -0,0 +1,136 @@
+package regexp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// compileReverseProg compiles re into a program matching the reversed
+// input. Concatenations run backwards, ^ and $ trade places and
+// captures are dropped, since the program only locates match bounds.
+func compileReverseProg(re *Regexp) *Prog {
+	c := compiler{p: &Prog{}, reversed: true}
+	c.inst(InstFail)
+	f := c.compile(re)
+	f.out.patch(c.p, c.inst(InstMatch).i)
+	c.p.Start = int(f.i)
+	return c.p
+}
+
+// anchoredStart reports whether every path through p asserts
+// a line start before it consumes a rune or matches.
+func anchoredStart(p *Prog) bool {
+	seen := make(map[uint32]bool)
+	var walk func(pc uint32) bool
+	walk = func(pc uint32) bool {
+		if pc == 0 || seen[pc] {
+			return true
+		}
+		seen[pc] = true
+		i := &p.Inst[pc]
+		switch i.Op {
+		case InstAlt:
+			return walk(i.Out) && walk(i.Arg)
+		case InstEmptyWidth:
+			return EmptyOp(i.Arg)&EmptyLineStart != 0 || walk(i.Out)
+		case InstCapture, InstNop:
+			return walk(i.Out)
+		case InstRune, InstMatch:
+			return false
+		}
+		return true
+	}
+	return walk(uint32(p.Start))
+}
+
+// singleLine reports whether no instruction of p consumes a newline.
+func singleLine(p *Prog) bool {
+	for pc := range p.Inst {
+		if i := &p.Inst[pc]; i.Op == InstRune && i.matchRune('\n', false) {
+			return false
+		}
+	}
+	return true
+}
+
+// getReverseDFA returns the pool of anchored leftmost-longest DFAs
+// of the reversed program of re, building it if necessary.
+func (re *Regexp) getReverseDFA() *dfaPool {
+	re.rdfaOnce.Do(func() {
+		p := compileReverseProg(re)
+		re.rdfa = &dfaPool{new: func() *dfa {
+			d := newDFA(p, p.Start, true, true)
+			d.reversed = true
+			return d
+		}}
+		re.endAnchored = anchoredStart(p) && singleLine(p)
+	})
+	return re.rdfa
+}
+
+// searchReverse runs a reversed DFA over str backwards from end,
+// stopping at stop. It returns the smallest position where a match
+// starts and whether there was one. If the state cache thrashes,
+// searchReverse gives up and returns ok == false.
+func (d *dfa) searchReverse(str string, end, stop int, c call) (start int, matched bool, ok bool) {
+	run := d.start(str, end, c)
+	start = -1
+	for pos := end; ; {
+		run.tick()
+		r, w := endOfText, 0
+		if pos > 0 {
+			r, w = utf8.DecodeLastRuneInString(str[:pos])
+		}
+		s := run.step(r, pos)
+		if s == nil {
+			return -1, false, false
+		}
+		if s.flags&flagMatch != 0 {
+			start = pos
+		}
+		if pos <= stop || w == 0 || len(s.insts) == 0 {
+			break
+		}
+		pos -= w
+	}
+	return start, start >= 0, true
+}
+
+// doDFA finds the leftmost match in str at or after pos without
+// captures. A forward DFA locates the end of the match and the
+// reversed DFA its start. Patterns whose matches end at a line end
+// and never span lines are instead searched backwards from every
+// line end in turn. If a DFA gives up, doDFA returns ok == false.
+func (re *Regexp) doDFA(str string, pos int, c call) (matches []int, ok bool) {
+	rdp := re.getReverseDFA()
+	rd := rdp.get(c.fold)
+	defer rdp.put(rd)
+	if re.endAnchored {
+		for {
+			end := len(str)
+			if i := strings.IndexByte(str[pos:], '\n'); i >= 0 {
+				end = pos + i
+			}
+			start, matched, ok := rd.searchReverse(str, end, pos, c)
+			if !ok {
+				return nil, false
+			}
+			if matched {
+				return []int{start, end}, true
+			}
+			if end == len(str) {
+				return nil, true
+			}
+			pos = end + 1
+		}
+	}
+	end, matched, ok := re.getDFA().search(str, pos, false, c)
+	if !ok || !matched {
+		return nil, ok
+	}
+	start, matched, ok := rd.searchReverse(str, end, pos, c)
+	if !ok || !matched {
+		return nil, false
+	}
+	return []int{start, end}, true
+}
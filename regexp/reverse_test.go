@@ -0,0 +1,88 @@
+package regexp
+
+import (
+	stdregexp "regexp"
+	"strings"
+	"testing"
+)
+
+var reverseTests = []struct {
+	pattern, input string
+}{
+	{"\\d+[.]log$", "a.log\n12.log\nx"},
+	{"\\d+[.]log$", "12.logx\n"},
+	{"[a-z]+$", "ab cd\nef"},
+	{"[a-z]*$", "ab1\n"},
+	{"^ab$", "x\nab\n"},
+	{"a|ab$", "xab"},
+	{"x*$", "xx\nxx"},
+	{"(\\w+) (\\w+)", "  hello world  "},
+	{"\\bb", "ab b"},
+	{"[^x]*$", "ax\nbb"},
+}
+
+func TestReverse(t *testing.T) {
+	for _, tests := range [][]struct{ pattern, input string }{matchTests, reverseTests} {
+		for _, test := range tests {
+			re := FromInfixExp(test.pattern)
+			std := stdregexp.MustCompile("(?m)" + test.pattern)
+			want := std.FindStringIndex(test.input)
+			got, ok := re.doDFA(test.input, 0, call{})
+			if !ok || !intsEqual(got, want) {
+				t.Errorf("error: %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+			}
+			re.Longest()
+			std.Longest()
+			want = std.FindStringIndex(test.input)
+			if got, ok := re.doDFA(test.input, 0, call{}); !ok || !intsEqual(got, want) {
+				t.Errorf("error: longest %q on %q\ngot: %v\nwant: %v", test.pattern, test.input, got, want)
+			}
+		}
+	}
+}
+
+func TestEndAnchored(t *testing.T) {
+	for _, test := range []struct {
+		pattern     string
+		endAnchored bool
+	}{
+		{"\\d+[.]log$", true},
+		{"(a|b$)$", true},
+		{"^ab$", true},
+		{"ab", false},
+		{"a|b$", false},
+		{"[^x]*$", false},
+	} {
+		re := FromInfixExp(test.pattern)
+		re.getReverseDFA()
+		if re.endAnchored != test.endAnchored {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.pattern, re.endAnchored, test.endAnchored)
+		}
+	}
+}
+
+func TestFindAllStringIndexReverse(t *testing.T) {
+	input := strings.Repeat("app 1.log\nerr.log\n42.log\n", 3)
+	re := FromInfixExp("\\d+[.]log$")
+	want := stdregexp.MustCompile("(?m)\\d+[.]log$").FindAllStringIndex(input, -1)
+	got := re.FindAllStringIndex(input, -1, false)
+	if len(got) != len(want) {
+		t.Fatalf("error:\ngot: %v\nwant: %v", got, want)
+	}
+	for j := range got {
+		if !intsEqual(got[j], want[j]) {
+			t.Errorf("error:\ngot: %v\nwant: %v", got, want)
+		}
+	}
+}
+
+func BenchmarkEndAnchored(b *testing.B) {
+	re := FromInfixExp("\\d+[.]log$")
+	input := strings.Repeat(strings.Repeat("x", 200)+"\n", 1000) + "42.log"
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if m, _ := re.doDFA(input, 0, call{}); m == nil {
+			b.Fatal("no match")
+		}
+	}
+}
@@ -68,6 +68,48 @@ func negateClass(rr RuneRange) RuneRange {
 	return rr
 }
 
+// subtractClass appends the runes of rr that are not in minus to out.
+// It assumes both are clean. It gives up and returns false once the
+// ranges appended would reach limit elements.
+func subtractClass(out, rr, minus RuneRange, limit int) (RuneRange, bool) {
+	limit += len(out)
+	j := 0
+	for i := 0; i < len(rr); i += 2 {
+		lo, hi := rr[i], rr[i+1]
+		for j < len(minus) && minus[j+1] < lo {
+			j += 2
+		}
+		for k := j; k < len(minus) && minus[k] <= hi && lo <= hi; k += 2 {
+			if minus[k] > lo {
+				out = append(out, lo, minus[k]-1)
+			}
+			lo = minus[k+1] + 1
+		}
+		if lo <= hi {
+			out = append(out, lo, hi)
+		}
+		if len(out) >= limit {
+			return out, false
+		}
+	}
+	return out, true
+}
+
+// subClass reports whether every rune of sub is in rr.
+// It assumes both are clean.
+func subClass(sub, rr RuneRange) bool {
+	j := 0
+	for i := 0; i < len(sub); i += 2 {
+		for j < len(rr) && rr[j+1] < sub[i] {
+			j += 2
+		}
+		if j == len(rr) || rr[j] > sub[i] || rr[j+1] < sub[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
 // cleanClass sorts the ranges (pairs of elements of r),
 // merges them, and eliminates duplicates.
 func cleanClass(rrp *RuneRange) RuneRange {
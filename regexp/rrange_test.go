@@ -89,6 +89,30 @@ func TestAppendLiteral(t *testing.T) {
 	}
 }
 
+func TestSubtractClass(t *testing.T) {
+	for _, test := range []struct {
+		rr, minus, want RuneRange
+		sub             bool
+	}{
+		{RuneRange{48, 57}, RuneRange{50, 52}, RuneRange{48, 49, 53, 57}, true},
+		{RuneRange{48, 57, 65, 90}, RuneRange{48, 57}, RuneRange{65, 90}, true},
+		{RuneRange{48, 57}, RuneRange{40, 50, 55, 60}, RuneRange{51, 54}, false},
+		{RuneRange{48, 57}, RuneRange{48, 57}, RuneRange{}, true},
+		{RuneRange{48, 49}, RuneRange{}, RuneRange{48, 49}, true},
+	} {
+		got, ok := subtractClass(nil, test.rr, test.minus, 10)
+		if !ok || !utils.Equal(got, test.want) {
+			t.Errorf("error: %v minus %v\ngot:  %v %v\nwant: %v", test.rr, test.minus, got, ok, test.want)
+		}
+		if sub := subClass(test.minus, test.rr); sub != test.sub {
+			t.Errorf("error: %v in %v\ngot:  %v\nwant: %v", test.minus, test.rr, sub, test.sub)
+		}
+	}
+	if _, ok := subtractClass(nil, RuneRange{48, 57}, RuneRange{50, 52}, 4); ok {
+		t.Errorf("error: subtraction beyond its limit succeeded")
+	}
+}
+
 func TestCharClasses(t *testing.T) {
 	for _, test := range []struct {
 		c0, c1 uint8
@@ -0,0 +1,158 @@
+package regexp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// DefaultMaxMatchLen is the default upper bound, in bytes, on the length
+// of a match reported by a Scanner.
+const DefaultMaxMatchLen = bufio.MaxScanTokenSize
+
+// ErrMatchTooLong is returned when a match is longer than the configured
+// maximum match length.
+var ErrMatchTooLong = errors.New("regexp: match exceeds maximum match length")
+
+// A Scanner reads input from an io.Reader and reports every
+// non-overlapping match of a Regexp together with its absolute byte offsets.
+//
+// Input is read in chunks. A match is only reported once more than
+// MaxMatchLen bytes following its start have been read (or the input
+// is exhausted), so matches straddling chunk boundaries are found intact.
+// Assertions see the rune before the start of a search, also across
+// chunks. Empty matches at the very end of the input are not reported.
+type Scanner struct {
+	sc    *bufio.Scanner
+	split splitter
+	off   int64 // absolute offset of the data handed to the split function
+	start int64 // absolute offset of the current match
+	end   int64
+	used  bool
+}
+
+// A splitter finds the successive matches of re in the chunks of input
+// handed to a bufio.SplitFunc. It keeps the rune before the search
+// position at the front of the next chunk as context for assertions.
+type splitter struct {
+	re     *Regexp
+	i      bool
+	maxLen int
+	back   int // bytes at the front of the chunk before the search position
+}
+
+// NewScanner returns a new Scanner that reports the matches of re in r.
+func NewScanner(re *Regexp, r io.Reader, i bool) *Scanner {
+	s := &Scanner{sc: bufio.NewScanner(r), split: splitter{re: re, i: i}}
+	s.MaxMatchLen(DefaultMaxMatchLen)
+	return s
+}
+
+// MaxMatchLen sets the maximum length of a match in bytes.
+// Longer matches stop the Scanner with ErrMatchTooLong.
+// MaxMatchLen panics if it is called after scanning has started.
+func (s *Scanner) MaxMatchLen(n int) {
+	if s.used {
+		panic("regexp: MaxMatchLen called after Scan")
+	}
+	if n < 1 {
+		n = 1
+	}
+	s.split.maxLen = n
+	s.sc.Buffer(make([]byte, 0, 4096), 2*n+4096+utf8.UTFMax)
+	s.sc.Split(s.splitFunc)
+}
+
+func (s *Scanner) splitFunc(data []byte, atEOF bool) (int, []byte, error) {
+	advance, start, token, err := s.split.match(data, atEOF)
+	if token != nil {
+		s.start = s.off + int64(start)
+		s.end = s.start + int64(len(token))
+	}
+	s.off += int64(advance)
+	return advance, token, err
+}
+
+// Scan advances the Scanner to the next match, which will then be
+// available through the Bytes, Text and Index methods. It returns false
+// when there are no more matches or an error occurred.
+func (s *Scanner) Scan() bool {
+	s.used = true
+	return s.sc.Scan()
+}
+
+// Bytes returns the most recent match found by a call to Scan.
+// The underlying array may point to data that will be overwritten
+// by a subsequent call to Scan.
+func (s *Scanner) Bytes() []byte {
+	return s.sc.Bytes()
+}
+
+// Text returns the most recent match found by a call to Scan
+// as a newly allocated string.
+func (s *Scanner) Text() string {
+	return s.sc.Text()
+}
+
+// Index returns the absolute byte offsets of the most recent match.
+func (s *Scanner) Index() (start, end int64) {
+	return s.start, s.end
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.sc.Err()
+}
+
+// SplitFunc returns a bufio.SplitFunc that yields the matches of re as
+// tokens and discards the input between them. maxLen bounds the length
+// of a match in bytes, see Scanner.MaxMatchLen. The function keeps
+// state between calls and serves a single bufio.Scanner.
+func (re *Regexp) SplitFunc(maxLen int, i bool) bufio.SplitFunc {
+	sp := &splitter{re: re, i: i, maxLen: maxLen}
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, _, token, err := sp.match(data, atEOF)
+		return advance, token, err
+	}
+}
+
+// match looks for the leftmost match in data after the context bytes
+// at its front. It returns the number of bytes to advance, the start
+// of the match within data and the match itself. A nil token with a
+// non-zero advance discards input that cannot contain the start of
+// a match.
+func (sp *splitter) match(data []byte, atEOF bool) (int, int, []byte, error) {
+	pos := min(sp.back, len(data))
+	if atEOF && pos == len(data) {
+		sp.back = 0
+		return len(data), 0, nil, nil
+	}
+	a := sp.re.doExecute(string(data), pos, 0, call{fold: sp.i})
+	switch {
+	case a == nil && atEOF:
+		sp.back = 0
+		return len(data), 0, nil, nil
+	case a == nil:
+		// Keep the tail that might still hold the start of a match.
+		return sp.resume(data, max(pos, len(data)-sp.maxLen)), 0, nil, nil
+	case !atEOF && len(data)-a[0] <= sp.maxLen:
+		// The match might grow once more input is available, and
+		// assertions need the byte after the longest allowed match.
+		return sp.resume(data, a[0]), 0, nil, nil
+	case a[1]-a[0] > sp.maxLen:
+		return 0, 0, nil, ErrMatchTooLong
+	case a[0] == a[1]:
+		_, width := utf8.DecodeRune(data[a[0]:])
+		return sp.resume(data, a[0]+width), a[0], data[a[0]:a[0]], nil
+	}
+	return sp.resume(data, a[1]), a[0], data[a[0]:a[1]], nil
+}
+
+// resume returns the number of bytes to advance so that the next
+// search starts at pos with the rune before pos kept as context.
+func (sp *splitter) resume(data []byte, pos int) int {
+	_, width := utf8.DecodeLastRune(data[:pos])
+	sp.back = width
+	return pos - width
+}
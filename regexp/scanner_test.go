@@ -0,0 +1,160 @@
+package regexp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestScanner(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+		want           []string
+		index          [][2]int64
+	}{
+		{"[a-z]+=\\d+", "x=1 key=123 ab=7",
+			[]string{"x=1", "key=123", "ab=7"},
+			[][2]int64{{0, 3}, {4, 11}, {12, 16}}},
+		{"\\d+", "abc 12345 def 6",
+			[]string{"12345", "6"},
+			[][2]int64{{4, 9}, {14, 15}}},
+		{"ab", "xxabxxabab",
+			[]string{"ab", "ab", "ab"},
+			[][2]int64{{2, 4}, {6, 8}, {8, 10}}},
+		{"\\w+", "∑∑ héllo", []string{"héllo"}, [][2]int64{{7, 13}}},
+		{"z", "aaaa", nil, nil},
+	} {
+		re := FromInfixExp(test.pattern)
+		s := NewScanner(&re, iotest.OneByteReader(strings.NewReader(test.input)), false)
+		s.MaxMatchLen(16)
+		var got []string
+		var index [][2]int64
+		for s.Scan() {
+			start, end := s.Index()
+			got = append(got, s.Text())
+			index = append(index, [2]int64{start, end})
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("error: %q on %q: %v", test.pattern, test.input, err)
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("error: %q on %q\ngot: %q\nwant: %q",
+				test.pattern, test.input, got, test.want)
+		}
+		if len(index) != len(test.index) {
+			t.Errorf("error: %q on %q\ngot: %v\nwant: %v",
+				test.pattern, test.input, index, test.index)
+			continue
+		}
+		for i := range index {
+			if index[i] != test.index[i] {
+				t.Errorf("error: %q on %q\ngot: %v\nwant: %v",
+					test.pattern, test.input, index, test.index)
+				break
+			}
+		}
+	}
+}
+
+func TestScannerMatchTooLong(t *testing.T) {
+	re := FromInfixExp("a+")
+	s := NewScanner(&re, strings.NewReader("b"+strings.Repeat("a", 100)), false)
+	s.MaxMatchLen(10)
+	for s.Scan() {
+	}
+	if s.Err() != ErrMatchTooLong {
+		t.Errorf("error:\ngot: %v\nwant: %v", s.Err(), ErrMatchTooLong)
+	}
+}
+
+// TestScannerMaxMatchLen checks that matches reaching the maximum
+// length do not depend on how the reader splits the input.
+func TestScannerMaxMatchLen(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+		maxLen         int
+		want           []string
+		err            error
+	}{
+		{"a+", "xaaaaaay", 3, nil, ErrMatchTooLong},
+		{"a+", "xaaay", 3, []string{"aaa"}, nil},
+		{`ab\b`, "abc", 2, nil, nil},
+		{`ab\b`, "ab c", 2, []string{"ab"}, nil},
+		{"a{3}$", "aaab", 3, nil, nil},
+		{"a{3}$", "baaa", 3, []string{"aaa"}, nil},
+	} {
+		for _, oneByte := range []bool{false, true} {
+			var r io.Reader = strings.NewReader(test.input)
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+			re := FromInfixExp(test.pattern)
+			s := NewScanner(&re, r, false)
+			s.MaxMatchLen(test.maxLen)
+			var got []string
+			for s.Scan() {
+				got = append(got, s.Text())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) || s.Err() != test.err {
+				t.Errorf("error: %q on %q, one byte at a time: %v\ngot: %q %v\nwant: %q %v",
+					test.pattern, test.input, oneByte, got, s.Err(), test.want, test.err)
+			}
+		}
+	}
+}
+
+func TestSplitFunc(t *testing.T) {
+	re := FromInfixExp("[0-9]+")
+	sc := bufio.NewScanner(iotest.HalfReader(strings.NewReader("id=42, n=1000; x=7")))
+	sc.Split(re.SplitFunc(8, false))
+	var got []string
+	for sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	if want := "42,1000,7"; strings.Join(got, ",") != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, want)
+	}
+}
+
+// TestScannerContext checks that assertions at chunk boundaries and
+// right after a match see the rune before them, as FindAll does.
+func TestScannerContext(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+	}{
+		{`\bx`, "xx"},
+		{`\bx`, "x xx x"},
+		{`\Bx`, "xx xxx"},
+		{"^a", "aa\naa"},
+		{"^a+", "aaa\na"},
+		{"a$", "aa\na"},
+		{`\b\w+\b`, "héllo wörld"},
+		{`\B∑`, "∑∑ a∑"},
+	} {
+		re := FromInfixExp(test.pattern)
+		var want [][2]int64
+		for _, m := range re.FindAllStringIndex(test.input, -1, false) {
+			want = append(want, [2]int64{int64(m[0]), int64(m[1])})
+		}
+		for _, oneByte := range []bool{false, true} {
+			var r io.Reader = strings.NewReader(test.input)
+			if oneByte {
+				r = iotest.OneByteReader(r)
+			}
+			s := NewScanner(&re, r, false)
+			s.MaxMatchLen(16)
+			var got [][2]int64
+			for s.Scan() {
+				start, end := s.Index()
+				got = append(got, [2]int64{start, end})
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) || s.Err() != nil {
+				t.Errorf("error: %q on %q, one byte at a time: %v\ngot: %v %v\nwant: %v",
+					test.pattern, test.input, oneByte, got, s.Err(), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package regexp
+
+// A Set matches many regular expressions against an input in a single pass.
+// The patterns are compiled into one program that is run by a DFA.
+// A Set is safe for concurrent use by multiple goroutines.
+type Set struct {
+	res      []*Regexp
+	prog     *Prog
+	dfa      *dfaPool
+	anchored *dfaPool // anchored DFAs for LongestMatch
+}
+
+// CompileSet parses the regular expressions exprs and returns,
+// if successful, a Set matching all of them.
+func CompileSet(exprs []string) (*Set, error) {
+	s := &Set{}
+	for _, expr := range exprs {
+		re, err := Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.res = append(s.res, re)
+	}
+	s.prog = compileSetProg(s.res)
+	s.dfa = setDFAPool(s.prog, false)
+	s.anchored = setDFAPool(s.prog, true)
+	return s, nil
+}
+
+// setDFAPool returns a pool of set mode DFAs for p. The DFAs are
+// only built by the searches needing them.
+func setDFAPool(p *Prog, anchored bool) *dfaPool {
+	return &dfaPool{new: func() *dfa {
+		d := newDFA(p, p.Start, anchored, false)
+		d.set = true
+		return d
+	}}
+}
+
+// compileSetProg compiles the syntax trees res into a single program.
+// The match instruction of every pattern holds its index in Arg.
+func compileSetProg(res []*Regexp) *Prog {
+	c := compiler{p: &Prog{NumCap: 2}}
+	c.inst(InstFail)
+	var f frag
+	for j := len(res) - 1; j >= 0; j-- {
+		fj := c.compile(res[j])
+		m := c.inst(InstMatch)
+		c.p.Inst[m.i].Arg = uint32(j)
+		fj.out.patch(c.p, m.i)
+		fj.out = nil
+		if j == len(res)-1 {
+			f = fj
+		} else {
+			f = c.alt(fj, f)
+		}
+	}
+	c.p.Start = int(f.i)
+	return c.p
+}
+
+// Len returns the number of patterns in the set.
+func (s *Set) Len() int {
+	return len(s.res)
+}
+
+// Regexp returns the compiled n-th pattern of the set.
+func (s *Set) Regexp(n int) *Regexp {
+	return s.res[n]
+}
+
+// Matches returns the indices of the patterns that match str,
+// in increasing order.
+func (s *Set) Matches(str string, i bool) []int {
+	seen := make([]bool, len(s.res))
+	d := s.dfa.get(i)
+	ok := d.searchSet(str, seen, call{fold: i})
+	s.dfa.put(d)
+	if !ok {
+		// The DFA gave up, match the patterns one by one.
+		for n, re := range s.res {
+			seen[n] = re.doMatch(str, call{fold: i})
+		}
+	}
+	var result []int
+	for n, ok := range seen {
+		if ok {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// MatchString reports whether any pattern of the set matches str.
+func (s *Set) MatchString(str string, i bool) bool {
+	return len(s.Matches(str, i)) > 0
+}
+
+// MatchesIndex is like Matches but also returns the position of the
+// leftmost match of every matching pattern. Each element holds the
+// pattern index followed by the byte offsets of its match.
+func (s *Set) MatchesIndex(str string, i bool) [][]int {
+	var result [][]int
+	for _, n := range s.Matches(str, i) {
+		a := s.res[n].doNFA(str, 0, 0, call{fold: i})
+		if a != nil {
+			result = append(result, []int{n, a[0], a[1]})
+		}
+	}
+	return result
+}
+
+// LongestMatch looks for the longest match of any pattern of the set
+// starting exactly at pos in str. It returns the index of the matching
+// pattern, preferring the lowest index among equally long matches, and
+// the end of the match. If no pattern matches at pos, it returns -1, -1.
+func (s *Set) LongestMatch(str string, pos int, i bool) (pattern, end int) {
+	d := s.anchored.get(i)
+	pattern, end, ok := d.searchLongestSet(str, pos, call{fold: i})
+	s.anchored.put(d)
+	if ok {
+		return pattern, end
+	}
+
+	// The DFA gave up, run the patterns one by one.
+	pattern, end = -1, -1
+	for n, re := range s.res {
+		m := newMachine(re.getProg(), 2, true, call{fold: i})
+		m.anchored = true
+		if m.match(str, pos) && m.matchcap[1] > end {
+			pattern, end = n, m.matchcap[1]
+		}
+	}
+	return pattern, end
+}
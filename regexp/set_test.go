@@ -0,0 +1,86 @@
+package regexp
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s, err := CompileSet([]string{
+		"^/users/\\d+$",
+		"^/users/[a-z]+$",
+		"/static/",
+		"[.]css$",
+		"^/admin",
+		"\\bjs\\b",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		input   string
+		want    string
+		indexed string
+	}{
+		{"/users/42", "[0]", "[[0 0 9]]"},
+		{"/users/bob", "[1]", "[[1 0 10]]"},
+		{"/static/site.css", "[2 3]", "[[2 0 8] [3 12 16]]"},
+		{"/admin/static/js/app", "[2 4 5]", "[[2 6 14] [4 0 6] [5 14 16]]"},
+		{"/users/bob/", "[]", "[]"},
+	} {
+		if got := fmt.Sprint(s.Matches(test.input, false)); got != test.want {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.input, got, test.want)
+		}
+		if got := fmt.Sprint(s.MatchesIndex(test.input, false)); got != test.indexed {
+			t.Errorf("error: %q\ngot: %v\nwant: %v", test.input, got, test.indexed)
+		}
+	}
+	if !s.MatchString("/USERS/BOB", true) {
+		t.Errorf("error: case-insensitive set match failed")
+	}
+}
+
+func TestSetConcurrent(t *testing.T) {
+	s, err := CompileSet([]string{"^/users/\\d+$", "/static/", "[.]css$", "[a-z]+", "[a-z]+/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if got, want := fmt.Sprint(s.Matches("/static/site.css", false)), "[1 2 3 4]"; got != want {
+					t.Errorf("error: Matches\ngot: %s\nwant: %s", got, want)
+				}
+				if n, end := s.LongestMatch("static/x", 0, false); n != 4 || end != 7 {
+					t.Errorf("error: LongestMatch\ngot: %d %d\nwant: 4 7", n, end)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompileSetError(t *testing.T) {
+	if _, err := CompileSet([]string{"a", "(b"}); err == nil {
+		t.Errorf("error: expected an error for \"(b\"")
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	b.StopTimer()
+	var exprs []string
+	for i := 0; i < 200; i++ {
+		exprs = append(exprs, fmt.Sprintf("^/api/v%d/items/\\d+$", i))
+	}
+	s, _ := CompileSet(exprs)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if len(s.Matches("/api/v199/items/12345", false)) != 1 {
+			b.Fatal("no match")
+		}
+	}
+}
@@ -1,9 +1,277 @@
 package regexp
 
+import (
+	"slices"
+	"unicode"
+)
+
+// simplifyRegexp simplifies the tree built by fromSyntaxTree and
+// appends the final OpAccept.
 func simplifyRegexp(re *Regexp) *Regexp {
+	return acceptRegexp(simplify(re))
+}
+
+// acceptRegexp appends the final OpAccept to re.
+func acceptRegexp(re *Regexp) *Regexp {
 	if re.Op != OpConcat {
 		return &Regexp{Op: OpConcat, Sub: []*Regexp{re, {Op: OpAccept}}}
 	}
 	re.Sub = append(re.Sub, &Regexp{Op: OpAccept})
 	return re
 }
+
+// simplify rewrites re bottom-up into an equivalent but smaller tree.
+// Matches, including the positions of capture groups, stay the same
+// under both leftmost-first and leftmost-longest semantics.
+func simplify(re *Regexp) *Regexp {
+	for j, sub := range re.Sub {
+		re.Sub[j] = simplify(sub)
+	}
+	switch re.Op {
+	case OpCharClass:
+		if len(re.Sym) == 2 && re.Sym[0] == re.Sym[1] {
+			return &Regexp{Op: OpLiteral, Sym: RuneRange{re.Sym[0]}}
+		}
+	case OpRepeat:
+		return simplifyRepeat(re)
+	case OpConcat:
+		return simplifyConcat(re)
+	case OpAlternate:
+		return simplifyAlternate(re)
+	}
+	return re
+}
+
+// emptyRegexp returns a node matching the empty string.
+func emptyRegexp() *Regexp {
+	return &Regexp{Op: OpConcat}
+}
+
+// isEmpty reports whether re matches only the empty string and
+// captures nothing, as an empty concatenation or x{0} does.
+func isEmpty(re *Regexp) bool {
+	switch re.Op {
+	case OpConcat:
+		return len(re.Sub) == 0
+	case OpRepeat:
+		return re.Max == 0 && !hasCapture(re.Sub[0])
+	}
+	return false
+}
+
+// simplifyRepeat removes x{1} and folds directly nested repeats such
+// as x**. x{0} is kept for simplifyConcat to drop, since the printer
+// has no other way to write an empty tree on its own, and repeats of
+// capture groups are left as written.
+func simplifyRepeat(re *Regexp) *Regexp {
+	sub := re.Sub[0]
+	switch {
+	case re.Min == 1 && re.Max == 1:
+		return sub
+	case isEmpty(sub):
+		return sub
+	}
+	if sub.Op == OpRepeat && isSimpleRepeat(re) && isSimpleRepeat(sub) {
+		// x**, x+*, x?+ and friends all match x*, except x++ and x??.
+		if re.Min == sub.Min && re.Max == sub.Max {
+			return sub
+		}
+		return &Regexp{Op: OpRepeat, Min: 0, Max: -1, Sub: sub.Sub}
+	}
+	return re
+}
+
+// isSimpleRepeat reports whether re is one of x*, x+ or x?.
+func isSimpleRepeat(re *Regexp) bool {
+	return re.Min == 0 && re.Max == -1 || re.Min == 1 && re.Max == -1 || re.Min == 0 && re.Max == 1
+}
+
+func hasCapture(re *Regexp) bool {
+	if re.Op == OpCapture {
+		return true
+	}
+	return slices.ContainsFunc(re.Sub, hasCapture)
+}
+
+// simplifyConcat flattens nested concatenations, drops empty
+// subexpressions and merges adjacent literals into strings.
+func simplifyConcat(re *Regexp) *Regexp {
+	var subs []*Regexp
+	for _, sub := range re.Sub {
+		switch {
+		case sub.Op == OpConcat:
+			subs = append(subs, sub.Sub...)
+		case !isEmpty(sub):
+			subs = append(subs, sub)
+		}
+	}
+	if len(subs) == 0 && len(re.Sub) > 0 {
+		// Keep one empty subexpression, as in a{0}b{0} => a{0}.
+		return re.Sub[0]
+	}
+	out := subs[:0]
+	merged := false // whether out ends in a literal built here
+	for _, sub := range subs {
+		if n := len(out); n > 0 && sub.Op == OpLiteral && out[n-1].Op == OpLiteral {
+			if !merged {
+				out[n-1] = &Regexp{Op: OpLiteral, Sym: slices.Clone(out[n-1].Sym)}
+				merged = true
+			}
+			out[n-1].Sym = append(out[n-1].Sym, sub.Sym...)
+			continue
+		}
+		out = append(out, sub)
+		merged = false
+	}
+	if len(out) == 1 {
+		return out[0]
+	}
+	re.Sub = out
+	return re
+}
+
+// simplifyAlternate flattens nested alternations, merges adjacent
+// single-rune branches into one character class and factors common
+// literal prefixes out of adjacent branches.
+func simplifyAlternate(re *Regexp) *Regexp {
+	var subs []*Regexp
+	for _, sub := range re.Sub {
+		if sub.Op == OpAlternate {
+			subs = append(subs, sub.Sub...)
+		} else {
+			subs = append(subs, sub)
+		}
+	}
+	subs = mergeRunes(subs)
+	subs = factorPrefixes(subs)
+	if len(subs) == 1 {
+		return subs[0]
+	}
+	re.Sub = subs
+	return re
+}
+
+// singleRune returns the runes matched by re
+// if re matches exactly one rune.
+func singleRune(re *Regexp) (RuneRange, bool) {
+	switch {
+	case re.Op == OpLiteral && len(re.Sym) == 1:
+		return RuneRange{re.Sym[0], re.Sym[0]}, true
+	case re.Op == OpCharClass:
+		return re.Sym, true
+	}
+	return nil, false
+}
+
+// mergeRunes merges runs of adjacent single-rune branches. Each of them
+// consumes exactly one rune and has nothing to capture, so their order
+// does not matter. A literal equal under case folding to a rune of the
+// run so far starts a new run, which keeps a|A as written.
+func mergeRunes(subs []*Regexp) []*Regexp {
+	var out []*Regexp
+	for j := 0; j < len(subs); {
+		k := j
+		var rr RuneRange
+		for ; k < len(subs); k++ {
+			r, ok := singleRune(subs[k])
+			if !ok || subs[k].Op == OpLiteral && foldsInto(r[0], rr) {
+				break
+			}
+			rr = appendClass(rr, r)
+		}
+		if k-j < 2 {
+			out = append(out, subs[j])
+			j++
+			continue
+		}
+		out = append(out, simplify(&Regexp{Op: OpCharClass, Sym: cleanClass(&rr)}))
+		j = k
+	}
+	return out
+}
+
+// foldsInto reports whether a rune other than r but equal to it under
+// case folding is in rr.
+func foldsInto(r rune, rr RuneRange) bool {
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if rr.find(f) != noMatch {
+			return true
+		}
+	}
+	return false
+}
+
+// leadingLiteral returns the literal re starts with, if any.
+func leadingLiteral(re *Regexp) RuneRange {
+	switch {
+	case re.Op == OpLiteral:
+		return re.Sym
+	case re.Op == OpConcat && len(re.Sub) > 0 && re.Sub[0].Op == OpLiteral:
+		return re.Sub[0].Sym
+	}
+	return nil
+}
+
+// trimLiteral returns re without its first n literal runes,
+// or nil if nothing would be left.
+func trimLiteral(re *Regexp, n int) *Regexp {
+	if re.Op == OpLiteral {
+		if n == len(re.Sym) {
+			return nil
+		}
+		return &Regexp{Op: OpLiteral, Sym: re.Sym[n:]}
+	}
+	subs := slices.Clone(re.Sub)
+	if n == len(subs[0].Sym) {
+		subs = subs[1:]
+	} else {
+		subs[0] = &Regexp{Op: OpLiteral, Sym: subs[0].Sym[n:]}
+	}
+	if len(subs) == 1 {
+		return subs[0]
+	}
+	return &Regexp{Op: OpConcat, Sub: subs}
+}
+
+// factorPrefixes rewrites runs of adjacent branches sharing a literal
+// prefix, as in abc|abd => ab(c|d). Runs where a branch consists of
+// the prefix alone are left as they are.
+func factorPrefixes(subs []*Regexp) []*Regexp {
+	var out []*Regexp
+	for j := 0; j < len(subs); {
+		prefix := leadingLiteral(subs[j])
+		k := j + 1
+		for ; k < len(subs) && len(prefix) > 0; k++ {
+			lit := leadingLiteral(subs[k])
+			n := 0
+			for n < len(prefix) && n < len(lit) && prefix[n] == lit[n] {
+				n++
+			}
+			if n == 0 {
+				break
+			}
+			prefix = prefix[:n]
+		}
+		if k-j < 2 || len(prefix) == 0 {
+			out = append(out, subs[j])
+			j++
+			continue
+		}
+		var rest []*Regexp
+		for _, sub := range subs[j:k] {
+			if r := trimLiteral(sub, len(prefix)); r != nil {
+				rest = append(rest, r)
+			}
+		}
+		if len(rest) < k-j {
+			out = append(out, subs[j:k]...)
+		} else {
+			alt := simplifyAlternate(&Regexp{Op: OpAlternate, Sub: rest})
+			out = append(out, simplifyConcat(&Regexp{Op: OpConcat, Sub: []*Regexp{
+				{Op: OpLiteral, Sym: slices.Clone(prefix)}, alt,
+			}}))
+		}
+		j = k
+	}
+	return out
+}
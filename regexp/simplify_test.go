@@ -0,0 +1,156 @@
+package regexp
+
+import (
+	stdregexp "regexp"
+	"testing"
+)
+
+var simplifyTests = []struct {
+	pattern, want string
+}{
+	// Literals are merged into strings.
+	{"abc", "abc"},
+	{"a(b)cd", "a(b)cd"},
+	{"[a]bc", "abc"},
+
+	// Alternations are flattened, and single runes merged into classes.
+	{"a|b|c", "[a-c]"},
+	{"a|b|cd|e|f", "[ab]|cd|[ef]"},
+	{"a|[b-d]|[0-9]", "[0-9a-d]"},
+	{"a|A", "a|A"},
+	{"(a|b)|(c|d)", "([ab])|([cd])"},
+
+	// Common prefixes are factored out of adjacent branches.
+	{"abc|abd", "ab[cd]"},
+	{"abc|abde|x", "ab(c|de)|x"},
+	{"foo|foobar", "foo|foobar"},
+	{"ab|cd|ab", "ab|cd|ab"},
+	{"a(b)|a(c)", "a((b)|(c))"},
+
+	// Repeats are folded.
+	{"a{1}", "a"},
+	{"xa{0}y", "xy"},
+	{"x(a){0}y", "x(a){0}y"},
+	{"a{0}b{0}", "a{0}"},
+	{"a{0}|b", "a{0}|b"},
+	{"(a{0})b", "(a{0})b"},
+	{"(a*)*", "(a*)*"},
+	{"(a+)+", "(a+)+"},
+	{"(a+)*", "(a+)*"},
+	{"(a?)*", "(a?)*"},
+	{"(a|b)*", "([ab])*"},
+	{"(ab){1}c", "(ab)c"},
+}
+
+func TestSimplify(t *testing.T) {
+	for _, test := range simplifyTests {
+		re := FromInfixExp(test.pattern)
+		if got := re.String(); got != test.want {
+			t.Errorf("error: %q\ngot: %q\nwant: %q", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestSimplifyNested(t *testing.T) {
+	for _, test := range []struct {
+		re   *Regexp
+		want string
+	}{
+		{&Regexp{Op: OpRepeat, Min: 0, Max: -1, Sub: []*Regexp{
+			{Op: OpRepeat, Min: 1, Max: -1, Sub: []*Regexp{fromRune('a')}},
+		}}, "a*"},
+		{&Regexp{Op: OpRepeat, Min: 1, Max: -1, Sub: []*Regexp{
+			{Op: OpRepeat, Min: 1, Max: -1, Sub: []*Regexp{fromRune('a')}},
+		}}, "a+"},
+		{&Regexp{Op: OpRepeat, Min: 0, Max: 1, Sub: []*Regexp{
+			{Op: OpRepeat, Min: 0, Max: 1, Sub: []*Regexp{fromRune('a')}},
+		}}, "a?"},
+		{&Regexp{Op: OpRepeat, Min: 0, Max: 1, Sub: []*Regexp{
+			{Op: OpRepeat, Min: 1, Max: -1, Sub: []*Regexp{fromRune('a')}},
+		}}, "a*"},
+		{&Regexp{Op: OpConcat, Sub: []*Regexp{
+			fromRune('a'),
+			{Op: OpConcat, Sub: []*Regexp{fromRune('b'), {Op: OpConcat}}},
+			{Op: OpConcat, Sub: []*Regexp{fromRune('c')}},
+		}}, "abc"},
+	} {
+		if got := simplify(test.re).String(); got != test.want {
+			t.Errorf("error:\ngot: %q\nwant: %q", got, test.want)
+		}
+	}
+}
+
+func TestSimplifyEquivalent(t *testing.T) {
+	tests := append(matchTests, []struct{ pattern, input string }{
+		{"abc|abd|abde", "xabdex"},
+		{"(abc|abd)(e|de)", "abdde"},
+		{"foo|foobar|fob", "foobar fob"},
+		{"a|b|(c)|d|e", "edcba"},
+		{"(a|ab)(c|bcd)(d*)", "abcd"},
+		{"((a*)*b)+", "aabab"},
+		{"((a+)+)(a)", "aaa"},
+		{"(a*)?(a)", "aa"},
+		{"x(a){0}y|x(b)", "xy xb"},
+		{"(a|b|cd|e|f)+", "abcdefx"},
+	}...)
+	for _, test := range tests {
+		re := FromInfixExp(test.pattern)
+		raw, err := Parse(test.pattern)
+		if err != nil {
+			t.Fatalf("error: %q: %v", test.pattern, err)
+		}
+		std := stdregexp.MustCompile("(?m)" + test.pattern)
+		want := std.FindStringSubmatchIndex(test.input)
+		if got := re.doNFA(test.input, 0, re.NumSubexp(), call{}); !intsEqual(got, want) {
+			t.Errorf("error: %q -> %q on %q\ngot: %v\nwant: %v", test.pattern, re.String(), test.input, got, want)
+		}
+		if got, want := re.FindAllStringIndex(test.input, -1, false),
+			raw.FindAllStringIndex(test.input, -1, false); len(got) != len(want) {
+			t.Errorf("error: all %q -> %q on %q\ngot: %v\nwant: %v", test.pattern, re.String(), test.input, got, want)
+		}
+
+		re.Longest()
+		std.Longest()
+		want = std.FindStringSubmatchIndex(test.input)
+		if got := re.doNFA(test.input, 0, re.NumSubexp(), call{}); !intsEqual(got, want) {
+			t.Errorf("error: longest %q -> %q on %q\ngot: %v\nwant: %v", test.pattern, re.String(), test.input, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	re, err := Parse("(a*)*b")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got, want := re.String(), "(a*)*b"; got != want {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, want)
+	}
+	if !re.MatchString("aab", false) {
+		t.Errorf("error: %q does not match %q", "(a*)*b", "aab")
+	}
+	if _, err := Parse("a{2,1}"); err == nil {
+		t.Errorf("error: invalid pattern parsed")
+	}
+}
+
+func TestSimplifyFold(t *testing.T) {
+	for _, test := range []struct {
+		pattern, input string
+	}{
+		{"a|c", "c"},
+		{"a|c", "C"},
+		{"a|c|e", "E"},
+		{"a|c|e|g|i|k", "K"},
+		{"a|c|e|g|i|k", "\u212a"},
+		{"[a-c]|x", "B"},
+	} {
+		re, err := Compile(test.pattern)
+		if err != nil {
+			t.Fatalf("error: %q: %v", test.pattern, err)
+		}
+		if !re.MatchString(test.input, true) {
+			t.Errorf("error: %q -> %q does not match %q ignoring case", test.pattern, re.String(), test.input)
+		}
+	}
+}
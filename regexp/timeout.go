@@ -0,0 +1,157 @@
+package regexp
+
+import (
+	"context"
+	"time"
+)
+
+// A Limit names the bound that stopped a match.
+type Limit uint8
+
+const (
+	StepLimit   Limit = iota // the step limit set with MaxSteps
+	TimeLimit                // the timeout set with Timeout
+	ContextDone              // the end of the context of the match
+)
+
+var limitNames = [...]string{
+	StepLimit:   "step limit exceeded",
+	TimeLimit:   "timeout exceeded",
+	ContextDone: "context done",
+}
+
+func (l Limit) String() string {
+	return limitNames[l]
+}
+
+// An ErrMatchTimeout is returned when a match runs out of its step
+// budget or time, or when its context is done. Err holds the error
+// of the context in the last case.
+type ErrMatchTimeout struct {
+	Limit Limit
+	Err   error
+}
+
+func (e *ErrMatchTimeout) Error() string {
+	if e.Err != nil {
+		return "regexp: match timeout: " + e.Limit.String() + ": " + e.Err.Error()
+	}
+	return "regexp: match timeout: " + e.Limit.String()
+}
+
+func (e *ErrMatchTimeout) Unwrap() error {
+	return e.Err
+}
+
+// checkInterval is the number of steps between two looks
+// at the clock and the context of a bounded match.
+const checkInterval = 1024
+
+// A budget bounds the work of a single match.
+type budget struct {
+	ctx      context.Context
+	deadline time.Time // zero if there is no timeout
+	maxSteps int       // 0 if there is no step limit
+	steps    int
+}
+
+// A timeout is the panic value unwinding a match that ran out of budget.
+type timeout struct {
+	err *ErrMatchTimeout
+}
+
+// tick charges one step to the match of c. Every engine calls it once
+// per step of its main loop. When the budget is exhausted, tick panics
+// with a timeout, which withBudget turns into an error.
+func (c *call) tick() {
+	b := c.budget
+	if b == nil {
+		return
+	}
+	b.steps++
+	if b.maxSteps > 0 && b.steps > b.maxSteps {
+		panic(timeout{&ErrMatchTimeout{Limit: StepLimit}})
+	}
+	if b.steps%checkInterval != 0 {
+		return
+	}
+	if err := b.ctx.Err(); err != nil {
+		panic(timeout{&ErrMatchTimeout{Limit: ContextDone, Err: err}})
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		panic(timeout{&ErrMatchTimeout{Limit: TimeLimit}})
+	}
+}
+
+// MaxSteps limits the number of engine steps a match taking a
+// context.Context may run before it fails with an ErrMatchTimeout.
+// A step is roughly the work of examining one rune. A limit of
+// zero or less means no limit. Only MatchStringContext and
+// FindAllStringContext honour the limit; the methods without a
+// context, which cannot report a timeout, always run to completion.
+func (re *Regexp) MaxSteps(n int) {
+	re.maxSteps = max(n, 0)
+}
+
+// Timeout limits the time a match taking a context.Context may run
+// before it fails with an ErrMatchTimeout. A timeout of zero or less
+// means no timeout. Only MatchStringContext and FindAllStringContext
+// honour the timeout; the methods without a context, which cannot
+// report it, always run to completion.
+func (re *Regexp) Timeout(d time.Duration) {
+	re.timeout = max(d, 0)
+}
+
+// withBudget runs f with a call bounded by the step limit and timeout
+// of re and the context ctx. It returns an *ErrMatchTimeout if f was
+// stopped.
+func (re *Regexp) withBudget(ctx context.Context, f func(c call)) (err error) {
+	if err := ctx.Err(); err != nil {
+		return &ErrMatchTimeout{Limit: ContextDone, Err: err}
+	}
+	b := &budget{ctx: ctx, maxSteps: re.maxSteps}
+	if re.timeout > 0 {
+		b.deadline = time.Now().Add(re.timeout)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			t, ok := r.(timeout)
+			if !ok {
+				panic(r)
+			}
+			err = t.err
+		}
+	}()
+	f(call{budget: b})
+	return nil
+}
+
+// MatchStringContext is like MatchString but gives up with an
+// *ErrMatchTimeout once ctx is done or the step limit or timeout
+// of re is exceeded.
+func (re *Regexp) MatchStringContext(ctx context.Context, str string, i bool) (bool, error) {
+	var matched bool
+	err := re.withBudget(ctx, func(c call) {
+		c.fold = i
+		matched = re.doMatch(str, c)
+	})
+	if err != nil {
+		return false, err
+	}
+	return matched, nil
+}
+
+// FindAllStringContext is like FindAllString but gives up with an
+// *ErrMatchTimeout once ctx is done or the step limit or timeout
+// of re is exceeded.
+func (re *Regexp) FindAllStringContext(ctx context.Context, str string, n int, i bool) ([]string, error) {
+	var result []string
+	err := re.withBudget(ctx, func(c call) {
+		c.fold = i
+		result = re.findAllString(str, n, c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package regexp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timeoutLimit returns the limit of err if it is an *ErrMatchTimeout.
+func timeoutLimit(err error) (Limit, bool) {
+	var e *ErrMatchTimeout
+	if !errors.As(err, &e) {
+		return 0, false
+	}
+	return e.Limit, true
+}
+
+func TestMatchStringContext(t *testing.T) {
+	re := FromInfixExp("[a-z]+=\\d+")
+	got, err := re.MatchStringContext(context.Background(), "key=123", false)
+	if err != nil || !got {
+		t.Errorf("error:\ngot: %v, %v\nwant: %v, %v", got, err, true, nil)
+	}
+
+	re.MaxSteps(3)
+	got, err = re.MatchStringContext(context.Background(), "key=123", false)
+	if limit, ok := timeoutLimit(err); !ok || limit != StepLimit || got {
+		t.Errorf("error:\ngot: %v, %v\nwant: %v, %v", got, err, false, StepLimit)
+	}
+	if !re.MatchString("key=123", false) {
+		t.Errorf("error: step limit applied outside of a context")
+	}
+
+	re.MaxSteps(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = re.MatchStringContext(ctx, "key=123", false)
+	if limit, ok := timeoutLimit(err); !ok || limit != ContextDone || !errors.Is(err, context.Canceled) {
+		t.Errorf("error:\ngot: %v\nwant: %v", err, context.Canceled)
+	}
+}
+
+func TestFindAllStringContext(t *testing.T) {
+	re := FromInfixExp("\\d+")
+	got, err := re.FindAllStringContext(context.Background(), "a1b22c333", -1, false)
+	want := []string{"1", "22", "333"}
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("error:\ngot: %v, %v\nwant: %v", got, err, want)
+	}
+	for j := range got {
+		if got[j] != want[j] {
+			t.Errorf("error:\ngot: %v\nwant: %v", got, want)
+		}
+	}
+
+	re.Timeout(time.Nanosecond)
+	input := strings.Repeat("x", 1<<20) + "1"
+	got, err = re.FindAllStringContext(context.Background(), input, -1, false)
+	if limit, ok := timeoutLimit(err); !ok || limit != TimeLimit {
+		t.Errorf("error:\ngot: %v, %v\nwant: %v", got, err, TimeLimit)
+	}
+}
+
+func TestErrMatchTimeout(t *testing.T) {
+	for _, test := range []struct {
+		err  *ErrMatchTimeout
+		want string
+	}{
+		{&ErrMatchTimeout{Limit: StepLimit}, "regexp: match timeout: step limit exceeded"},
+		{&ErrMatchTimeout{Limit: TimeLimit}, "regexp: match timeout: timeout exceeded"},
+		{&ErrMatchTimeout{Limit: ContextDone, Err: context.Canceled}, "regexp: match timeout: context done: context canceled"},
+	} {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("error:\ngot: %v\nwant: %v", got, test.want)
+		}
+	}
+}
+
+// TestEngineBudget checks that every engine stops once the budget is
+// exhausted.
+func TestEngineBudget(t *testing.T) {
+	input := strings.Repeat("ab", 100)
+	for _, test := range []struct {
+		name string
+		run  func(c call)
+	}{
+		{"dfa", func(c call) { re := FromInfixExp("b$"); re.getDFA().search(input, 0, false, c) }},
+		{"reverse", func(c call) {
+			re := FromInfixExp("(ab)*")
+			re.getReverseDFA().get(c.fold).searchReverse(input, len(input), 0, c)
+		}},
+		{"nfa", func(c call) { re := FromInfixExp("(a)c"); re.doNFA(input, 0, 1, c) }},
+		{"backtrack", func(c call) { re := FromInfixExp("(a)c"); re.doBacktrack(input, 0, 1, c) }},
+		{"onepass", func(c call) { re := FromInfixExp("^(ab)*$"); re.doOnePass(input, 0, 1, c) }},
+		{"ahocorasick", func(c call) {
+			re := FromInfixExp("aa|bb|cc|dd|ee|ff|gg|hh")
+			re.getAhoCorasick().findIndex(input, 0, c)
+		}},
+	} {
+		re := FromInfixExp("x")
+		re.MaxSteps(10)
+		err := re.withBudget(context.Background(), test.run)
+		if limit, ok := timeoutLimit(err); !ok || limit != StepLimit {
+			t.Errorf("error: %s\ngot: %v\nwant: %v", test.name, err, StepLimit)
+		}
+	}
+}
+
+// TestConcurrentBudget checks that the budget of a match does not
+// apply to matches running at the same time.
+// TestConcurrentBudget shares a bounded Regexp between goroutines,
+// whose calls each get a budget of their own.
+func TestConcurrentBudget(t *testing.T) {
+	short := "key=123"
+	long := strings.Repeat("x", 1000) + short
+	re := FromInfixExp("[a-z]+=\\d+")
+	re.MaxSteps(100)
+	var wg sync.WaitGroup
+	for n := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if n%2 == 0 {
+					if got, err := re.MatchStringContext(context.Background(), short, n%4 == 0); err != nil || !got {
+						t.Errorf("error: short match\ngot: %v, %v\nwant: %v, %v", got, err, true, nil)
+					}
+				} else if got, err := re.MatchStringContext(context.Background(), long, n%4 == 1); err == nil {
+					t.Errorf("error: long match\ngot: %v, %v\nwant: %v", got, err, StepLimit)
+				}
+				if !re.MatchString(long, n%3 == 0) {
+					t.Errorf("error: MatchString\ngot: %v\nwant: %v", false, true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
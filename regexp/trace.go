@@ -0,0 +1,106 @@
+package regexp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An EventKind is the kind of a step reported while tracing a match.
+type EventKind uint8
+
+const (
+	EventStart      EventKind = 1 + iota // a match attempt starts at Pos
+	EventEnter                           // an alternation, group or sequence of Node is entered
+	EventConsume                         // Rune at Pos is matched by Node
+	EventFail                            // Rune at Pos is not matched by Node
+	EventAssert                          // the assertion Node holds at Pos
+	EventAssertFail                      // the assertion Node does not hold at Pos
+	EventBacktrack                       // the backtracker resumes an untried branch at Pos
+	EventAccept                          // a match ends at Pos
+)
+
+var eventNames = []string{
+	EventStart:      "start",
+	EventEnter:      "enter",
+	EventConsume:    "consume",
+	EventFail:       "fail",
+	EventAssert:     "assert",
+	EventAssertFail: "assert fail",
+	EventBacktrack:  "backtrack",
+	EventAccept:     "accept",
+}
+
+func (k EventKind) String() string {
+	if int(k) >= len(eventNames) {
+		return ""
+	}
+	return eventNames[k]
+}
+
+// An Event is a single step of a traced match.
+type Event struct {
+	Kind EventKind
+	Pos  int     // byte offset in the input
+	PC   int     // instruction of the program, see Regexp.Prog
+	Rune rune    // the rune at Pos for EventConsume and EventFail, -1 at the end of the input
+	Node *Regexp // the subexpression the instruction was compiled from, if any
+}
+
+// trace reports an event at the instruction pc of p to the tracer
+// of c, if any.
+func (c *call) trace(p *Prog, kind EventKind, pc uint32, pos int, r rune) {
+	if c.tracer == nil {
+		return
+	}
+	var node *Regexp
+	if int(pc) < len(p.nodes) {
+		node = p.nodes[pc]
+	}
+	c.tracer(Event{Kind: kind, Pos: pos, PC: int(pc), Rune: r, Node: node})
+}
+
+// Trace finds the leftmost match of re in str like FindStringIndex,
+// calling f with every step the matching engine takes. The match runs
+// on the backtracker if the input is small enough and on the NFA
+// otherwise; the faster engines and the literal prefilter are skipped.
+// Trace returns the positions of the match followed by those of the
+// capture groups, or nil if there is no match.
+func (re *Regexp) Trace(str string, i bool, f func(Event)) []int {
+	c := call{fold: i, tracer: f}
+	if re.shouldBacktrack(len(str)) {
+		return re.doBacktrack(str, 0, re.NumSubexp(), c)
+	}
+	return re.doNFA(str, 0, re.NumSubexp(), c)
+}
+
+// FormatTrace replays the events of a match of re against str and
+// returns one line per event, showing the input position as a bar in
+// the input and the subexpression involved.
+func FormatTrace(re *Regexp, str string, events []Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pattern %s\ninput   %s\n", re, strconv.Quote(str))
+	for n, e := range events {
+		node := ""
+		if e.Node != nil {
+			node = e.Node.String()
+		}
+		switch e.Kind {
+		case EventConsume, EventFail:
+			r := "end of input"
+			if e.Rune >= 0 {
+				r = strconv.QuoteRune(e.Rune)
+			}
+			node = fmt.Sprintf("%s on %s", node, r)
+		case EventStart:
+			node = ""
+		}
+		at := strconv.Quote(str[:e.Pos]) + "|" + strconv.Quote(str[e.Pos:])
+		fmt.Fprintf(&b, "%4d  %-11s %4d  %s", n+1, e.Kind, e.Pos, at)
+		if node != "" {
+			b.WriteString("  " + node)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
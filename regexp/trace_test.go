@@ -0,0 +1,139 @@
+package regexp
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// kinds returns the kinds of events, leaving out EventEnter.
+func kinds(events []Event) []EventKind {
+	var ks []EventKind
+	for _, e := range events {
+		if e.Kind != EventEnter {
+			ks = append(ks, e.Kind)
+		}
+	}
+	return ks
+}
+
+func TestTrace(t *testing.T) {
+	for _, test := range []struct {
+		re, str string
+		want    []int
+		kinds   []EventKind
+	}{
+		{"ab", "ab", []int{0, 2}, []EventKind{EventStart, EventConsume, EventConsume, EventAccept}},
+		{"ab", "xb", nil, []EventKind{
+			EventStart, EventFail,
+			EventStart, EventFail,
+			EventStart, EventFail,
+		}},
+		{"ab|cd", "cd", []int{0, 2}, []EventKind{EventStart, EventFail, EventBacktrack, EventConsume, EventConsume, EventAccept}},
+		{"^a", "ba", nil, []EventKind{
+			EventStart, EventAssert, EventFail,
+			EventStart, EventAssertFail,
+			EventStart, EventAssertFail,
+		}},
+		{"a$", "a", []int{0, 1}, []EventKind{EventStart, EventConsume, EventAssert, EventAccept}},
+	} {
+		re := FromInfixExp(test.re)
+		var events []Event
+		got := re.Trace(test.str, false, func(e Event) { events = append(events, e) })
+		if len(got) == 0 {
+			got = nil
+		}
+		if !reflect.DeepEqual(got, test.want) || !reflect.DeepEqual(kinds(events), test.kinds) {
+			t.Errorf("error: %q on %q\ngot: %v %v\nwant: %v %v", test.re, test.str, got, kinds(events), test.want, test.kinds)
+		}
+	}
+}
+
+func TestTraceNodes(t *testing.T) {
+	re := FromInfixExp("a(b|c)+$")
+	var events []Event
+	re.Trace("xabd", false, func(e Event) { events = append(events, e) })
+	var consumed []string
+	for _, e := range events {
+		if e.Kind == EventConsume {
+			consumed = append(consumed, e.Node.String()+" "+string(e.Rune))
+		}
+		if e.Kind == EventFail && e.Pos == len("xabd") && e.Rune != -1 {
+			t.Errorf("error: rune at the end of the input\ngot: %q\nwant: %q", e.Rune, -1)
+		}
+	}
+	want := []string{"a a", "[bc] b"}
+	if !reflect.DeepEqual(consumed, want) {
+		t.Errorf("error: consumed\ngot: %q\nwant: %q", consumed, want)
+	}
+}
+
+func TestTraceNFA(t *testing.T) {
+	re := FromInfixExp("a+b")
+	var events []Event
+	got := re.doNFA("caab", 0, 0, call{tracer: func(e Event) { events = append(events, e) }})
+	if want := []int{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("error: match\ngot: %v\nwant: %v", got, want)
+	}
+	var accept, starts int
+	for _, e := range events {
+		switch e.Kind {
+		case EventStart:
+			starts++
+		case EventAccept:
+			accept = e.Pos
+		}
+	}
+	if starts != 5 || accept != 4 {
+		t.Errorf("error: starts and accept\ngot: %d %d\nwant: %d %d", starts, accept, 5, 4)
+	}
+}
+
+// TestTraceConcurrent checks that a trace only receives the steps of
+// its own match while other matches run at the same time.
+// TestTraceConcurrent shares a Regexp between goroutines tracing
+// it and matching it without a tracer.
+func TestTraceConcurrent(t *testing.T) {
+	re := FromInfixExp("a+b")
+	var want []Event
+	re.Trace("caab", false, func(e Event) { want = append(want, e) })
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if g%2 == 0 {
+					re.MatchString("xaaab", true)
+					re.FindAllString("ab aab", -1, false)
+					continue
+				}
+				var got []Event
+				re.Trace("caab", false, func(e Event) { got = append(got, e) })
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("error: events\ngot: %v\nwant: %v", got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFormatTrace(t *testing.T) {
+	re := FromInfixExp("a|b")
+	var events []Event
+	re.Trace("b", false, func(e Event) { events = append(events, e) })
+	got := FormatTrace(&re, "b", events)
+	for _, want := range []string{
+		"pattern [ab]\ninput   \"b\"\n",
+		"start          0  \"\"|\"b\"\n",
+		"accept         1  \"b\"|\"\"",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error: %q missing in\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package regexp
+
+import "unicode/utf8"
+
+// A width holds the bounds of the length of the matches of a tree,
+// in runes and in bytes. A maximum of -1 means no bound. If none is
+// set, the tree matches nothing, as an empty character class.
+type width struct {
+	minRunes, maxRunes int
+	minBytes, maxBytes int
+	none               bool
+}
+
+// MinLen returns the number of runes in the shortest match of re,
+// not ignoring case.
+func (re *Regexp) MinLen() int {
+	return re.width().minRunes
+}
+
+// MaxLen returns the number of runes in the longest match of re,
+// not ignoring case, or -1 if matches can be arbitrarily long.
+func (re *Regexp) MaxLen() int {
+	return re.width().maxRunes
+}
+
+// MinBytes returns the number of bytes in the shortest match of re,
+// not ignoring case.
+func (re *Regexp) MinBytes() int {
+	return re.width().minBytes
+}
+
+// MaxBytes returns the number of bytes in the longest match of re,
+// not ignoring case, or -1 if matches can be arbitrarily long.
+func (re *Regexp) MaxBytes() int {
+	return re.width().maxBytes
+}
+
+// FixedWidth reports whether every match of re, not ignoring case,
+// has the same number of runes, as required of lookbehinds.
+func (re *Regexp) FixedWidth() bool {
+	w := re.width()
+	return w.minRunes == w.maxRunes
+}
+
+// width computes the bounds of the length of the matches of re.
+// A tree matching nothing reports zero lengths.
+func (re *Regexp) width() width {
+	switch re.Op {
+	case OpLiteral:
+		return width{len(re.Sym), len(re.Sym), len(string(re.Sym)), len(string(re.Sym)), false}
+	case OpCharClass:
+		return classWidth(re.Sym)
+	case OpRepeat:
+		w := re.Sub[0].width()
+		if w.none {
+			if re.Min > 0 {
+				return w
+			}
+			return width{}
+		}
+		return width{
+			w.minRunes * re.Min, repeatMax(w.maxRunes, re.Max),
+			w.minBytes * re.Min, repeatMax(w.maxBytes, re.Max),
+			false,
+		}
+	case OpConcat:
+		var w width
+		for _, sub := range re.Sub {
+			sw := sub.width()
+			if sw.none {
+				return width{none: true}
+			}
+			w.minRunes += sw.minRunes
+			w.maxRunes = addMax(w.maxRunes, sw.maxRunes)
+			w.minBytes += sw.minBytes
+			w.maxBytes = addMax(w.maxBytes, sw.maxBytes)
+		}
+		return w
+	case OpAlternate:
+		w := width{none: true}
+		for _, sub := range re.Sub {
+			sw := sub.width()
+			if sw.none {
+				continue
+			}
+			if w.none {
+				w = sw
+				continue
+			}
+			w.minRunes = min(w.minRunes, sw.minRunes)
+			w.maxRunes = maxMax(w.maxRunes, sw.maxRunes)
+			w.minBytes = min(w.minBytes, sw.minBytes)
+			w.maxBytes = maxMax(w.maxBytes, sw.maxBytes)
+		}
+		return w
+	case OpCapture:
+		return re.Sub[0].width()
+	}
+	// Assertions and OpAccept match the empty string.
+	return width{}
+}
+
+// classWidth returns the width of a character class matching rr.
+func classWidth(rr RuneRange) width {
+	if len(rr) == 1 {
+		n := utf8.RuneLen(rr[0])
+		return width{1, 1, n, n, false}
+	}
+	w := width{1, 1, utf8.UTFMax, 0, true}
+	for i := 0; i+1 < len(rr); i += 2 {
+		lo, hi := rr[i], min(rr[i+1], utf8.MaxRune)
+		// Surrogates never occur in the input.
+		if 0xD800 <= lo && lo <= 0xDFFF {
+			lo = 0xE000
+		}
+		if 0xD800 <= hi && hi <= 0xDFFF {
+			hi = 0xD7FF
+		}
+		if lo > hi {
+			continue
+		}
+		w.none = false
+		w.minBytes = min(w.minBytes, utf8.RuneLen(lo))
+		w.maxBytes = max(w.maxBytes, utf8.RuneLen(hi))
+	}
+	if w.none {
+		return width{none: true}
+	}
+	return w
+}
+
+// repeatMax returns the largest length of up to count repetitions
+// of a match at most n long, where -1 means no bound.
+func repeatMax(n, count int) int {
+	switch {
+	case n == 0 || count == 0:
+		return 0
+	case n == -1 || count == -1:
+		return -1
+	}
+	return n * count
+}
+
+// addMax returns the sum of the bounds a and b, where -1 means no bound.
+func addMax(a, b int) int {
+	if a == -1 || b == -1 {
+		return -1
+	}
+	return a + b
+}
+
+// maxMax returns the larger of the bounds a and b, where -1 means no bound.
+func maxMax(a, b int) int {
+	if a == -1 || b == -1 {
+		return -1
+	}
+	return max(a, b)
+}
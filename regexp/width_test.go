@@ -0,0 +1,46 @@
+package regexp
+
+import (
+	"testing"
+
+	"github.com/tautastic/rex/utils"
+)
+
+func TestWidth(t *testing.T) {
+	for _, test := range []struct {
+		expr                       string
+		minLen, maxLen, minB, maxB int
+		fixed                      bool
+	}{
+		{"abc", 3, 3, 3, 3, true},
+		{"héllo", 5, 5, 6, 6, true},
+		{"a|bc", 1, 2, 1, 2, false},
+		{"[a-c]{2}x?", 2, 3, 2, 3, false},
+		{"a*", 0, -1, 0, -1, false},
+		{"a+b", 2, -1, 2, -1, false},
+		{"(ab){2,3}", 4, 6, 4, 6, false},
+		{"(a){0}", 0, 0, 0, 0, true},
+		{"^a$", 1, 1, 1, 1, true},
+		{`\bfoo\b`, 3, 3, 3, 3, true},
+		{".", 1, 1, 1, 4, true},
+		{"[aé]", 1, 1, 1, 2, true},
+		{"[é-€]", 1, 1, 2, 3, true},
+		{`\x{10000}`, 1, 1, 4, 4, true},
+		{`\p{Lu}{3}`, 3, 3, 3, 12, true},
+		{"(a|b*)c", 1, -1, 1, -1, false},
+		{"a?", 0, 1, 0, 1, false},
+		{"[^\\x{0}-\\x{10ffff}]|ab", 2, 2, 2, 2, true},
+		{"[^\\x{0}-\\x{10ffff}]*ab", 2, 2, 2, 2, true},
+		{"[^\\x{0}-\\x{10ffff}]", 0, 0, 0, 0, true},
+	} {
+		re, err := Compile(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []int{re.MinLen(), re.MaxLen(), re.MinBytes(), re.MaxBytes()}
+		want := []int{test.minLen, test.maxLen, test.minB, test.maxB}
+		if !utils.Equal(got, want) || re.FixedWidth() != test.fixed {
+			t.Errorf("error: %q\ngot: %v %v\nwant: %v %v", test.expr, got, re.FixedWidth(), want, test.fixed)
+		}
+	}
+}
@@ -2,36 +2,71 @@ package syntax
 
 import (
 	"fmt"
+)
+
+// A Kind identifies the grammar rule a Node was parsed from.
+type Kind uint8
 
-	"github.com/tautastic/rex/utils"
+const (
+	KindDisjunction Kind = 1 + iota // Sub: Term, optionally followed by Disjunction
+	KindTerm                        // Sub: Factor, optionally followed by Term
+	KindFactor                      // Sub: Assertion, or Atom optionally followed by Quantifier
+	KindAssertion                   // Value: one of ^ $ b B
+	KindQuantifier                  // Sub: two Numbers, the lower and upper bound
+	KindNumber                      // Value: decimal digits, or -1 for no upper bound
+	KindAtom                        // Sub: Dot, Literal, escape, Class or Disjunction
+	KindDot                         // the wildcard .
+	KindLiteral                     // Value: the rune
+	KindPerl                        // Value: one of d D s S w W
+	KindControl                     // Value: one of f n r t v
+	KindHexSeq                      // Value: hexadecimal digits
+	KindUniSeq                      // Value: the class name, prefixed by ^ if negated
+	KindClass                       // Sub: optional Negation, then ClassRanges and class atoms
+	KindNegation                    // the ^ opening a negated class
+	KindClassRange                  // Sub: the two class atoms bounding the range
 )
 
+var kindNames = []string{
+	KindDisjunction: "Disjunction",
+	KindTerm:        "Term",
+	KindFactor:      "Factor",
+	KindAssertion:   "Assertion",
+	KindQuantifier:  "Quantifier",
+	KindNumber:      "Number",
+	KindAtom:        "Atom",
+	KindDot:         "Dot",
+	KindLiteral:     "Literal",
+	KindPerl:        "Perl",
+	KindControl:     "Control",
+	KindHexSeq:      "HexSeq",
+	KindUniSeq:      "UniSeq",
+	KindClass:       "Class",
+	KindNegation:    "Negation",
+	KindClassRange:  "ClassRange",
+}
+
+func (k Kind) String() string {
+	if int(k) >= len(kindNames) {
+		return ""
+	}
+	return kindNames[k]
+}
+
+// A Node is a node in the parse tree of a pattern.
 type Node struct {
-	Label string
+	Kind  Kind
+	Value string // the text of leaf nodes, see Kind
+	Start int    // byte offset of the first byte of the node in the pattern
+	End   int    // byte offset just past the node in the pattern
 	Sub   []*Node
 }
 
 func (node Node) nodeStr() (str string) {
-	if utils.IsAnyOf(node.Label, []string{
-		"Disjunction",
-		"Term",
-		"Factor",
-		"Assertion",
-		"Quantifier",
-		"Atom",
-		"Perl",
-		"Control",
-		"HexSeq",
-		"UniSeq",
-		"Class",
-		"ClassRange",
-		"Literal",
-	}) {
-		str = fmt.Sprintf("<%v>", node.Label)
-	} else {
-		str = fmt.Sprintf("\"%v\"", node.Label)
+	str = fmt.Sprintf("<%v>", node.Kind)
+	if node.Value != "" {
+		str += fmt.Sprintf(" %q", node.Value)
 	}
-	return
+	return str + fmt.Sprintf(" [%d:%d]", node.Start, node.End)
 }
 
 func treeStr(t *Node, prefix string, arrow int) (str string) {
@@ -61,3 +96,39 @@ func treeStr(t *Node, prefix string, arrow int) (str string) {
 func (node Node) String() string {
 	return treeStr(&node, "", 0)
 }
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node *Node) (w Visitor)
+}
+
+// Walk traverses the parse tree rooted at node in depth-first order.
+// It starts by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node *Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, sub := range node.Sub {
+		Walk(v, sub)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(node *Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the parse tree rooted at node in depth-first order.
+// It starts by calling f(node); node must not be nil. If f returns true,
+// Inspect invokes f recursively for each of the children of node,
+// followed by a call of f(nil).
+func Inspect(node *Node, f func(*Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,93 @@
+package syntax
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		kind    Kind
+		want    []string
+	}{
+		{"ab|c", KindLiteral, []string{"a", "b", "c"}},
+		{"ab|c", KindTerm, []string{"ab", "b", "c"}},
+		{"a(b|c)*", KindAtom, []string{"a", "(b|c)", "b", "c"}},
+		{"a(b|c)*", KindQuantifier, []string{"*"}},
+		{"x{2,5}", KindNumber, []string{"2", "5"}},
+		{"\\d\\x{41}\\p{Lu}\\.", KindAtom, []string{"\\d", "\\x{41}", "\\p{Lu}", "\\."}},
+		{"[^\\n-\\r\\w]", KindClass, []string{"[^\\n-\\r\\w]"}},
+		{"[^\\n-\\r\\w]", KindClassRange, []string{"\\n-\\r"}},
+		{"[^\\n-\\r\\w]", KindPerl, []string{"\\w"}},
+		{"^é\\b$", KindAssertion, []string{"^", "\\b", "$"}},
+		{"^é\\b$", KindLiteral, []string{"é"}},
+	} {
+		var got []string
+		Inspect(ToSyntaxTree(test.pattern), func(node *Node) bool {
+			if node != nil && node.Kind == test.kind {
+				got = append(got, test.pattern[node.Start:node.End])
+			}
+			return true
+		})
+		if !slices.Equal(got, test.want) {
+			t.Errorf("error: %q %v\ngot: %q\nwant: %q", test.pattern, test.kind, got, test.want)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	root := ToSyntaxTree("a\\n\\x{1F}\\P{Lu}\\W+")
+	var got []string
+	Inspect(root, func(node *Node) bool {
+		if node != nil && node.Value != "" {
+			got = append(got, node.Kind.String()+" "+node.Value)
+		}
+		return true
+	})
+	want := []string{"Literal a", "Control n", "HexSeq 1F", "UniSeq ^Lu", "Perl W", "Number 1", "Number -1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("error:\ngot: %q\nwant: %q", got, want)
+	}
+}
+
+// depthVisitor records the depth of every node it visits.
+type depthVisitor struct {
+	depth  int
+	depths *[]int
+}
+
+func (v depthVisitor) Visit(node *Node) Visitor {
+	if node == nil || node.Kind == KindClass {
+		return nil
+	}
+	*v.depths = append(*v.depths, v.depth)
+	return depthVisitor{v.depth + 1, v.depths}
+}
+
+func TestWalk(t *testing.T) {
+	var depths []int
+	Walk(depthVisitor{depths: &depths}, ToSyntaxTree("a[bc]"))
+	// Disjunction, Term, Factor, Atom, Literal, Term, Factor, Atom;
+	// the class is pruned.
+	want := []int{0, 1, 2, 3, 4, 2, 3, 4}
+	if !slices.Equal(depths, want) {
+		t.Errorf("error:\ngot: %v\nwant: %v", depths, want)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	got := ToSyntaxTree("a|\\d").DOT()
+	for _, want := range []string{
+		"digraph syntax {",
+		`n0 [label="Disjunction\n[0:4]"];`,
+		`[label="Literal \"a\"\n[0:1]"];`,
+		`[label="Perl \"d\"\n[2:4]"];`,
+		"n0 -> n1;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("error: %q missing in\n%s", want, got)
+		}
+	}
+}
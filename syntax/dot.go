@@ -0,0 +1,33 @@
+package syntax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DOT returns the tree rooted at node in the Graphviz DOT language.
+// Every node is labelled with its kind, value and span.
+func (node *Node) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph syntax {\n\tnode [shape=box];\n")
+	n := 0
+	var walk func(node *Node) int
+	walk = func(node *Node) int {
+		id := n
+		n++
+		label := node.Kind.String()
+		if node.Value != "" {
+			label += " " + strconv.Quote(node.Value)
+		}
+		label += fmt.Sprintf("\n[%d:%d]", node.Start, node.End)
+		fmt.Fprintf(&b, "\tn%d [label=%s];\n", id, strconv.Quote(label))
+		for _, sub := range node.Sub {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", id, walk(sub))
+		}
+		return id
+	}
+	walk(node)
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -6,286 +6,384 @@ import (
 	"github.com/tautastic/rex/utils"
 )
 
-var pattern string
-var pos int
+// A parser holds the state of a single parse, so that patterns can be
+// parsed from several goroutines at once.
+type parser struct {
+	pattern string
+	pos     int
+	posix   bool   // restrict the accepted syntax to POSIX ERE
+	limits  Limits // bounds the pattern
+	depth   int    // current nesting of groups
+}
+
+// Limits bounds the patterns the parser accepts, so that neither its
+// recursion nor its output grow without bound. A zero field means no
+// limit.
+type Limits struct {
+	MaxLen   int // longest pattern in bytes
+	MaxDepth int // deepest nesting of groups
+}
 
 const endOfText rune = -1
 
-func peek(n int) rune {
-	if pos < len(pattern)+n {
-		c := pattern[pos+n]
+func (p *parser) peek(n int) rune {
+	if p.pos+n < len(p.pattern) {
+		c := p.pattern[p.pos+n]
 		if c < utf8.RuneSelf {
 			return rune(c)
 		}
-		r, _ := utf8.DecodeRuneInString(pattern[pos+n:])
+		r, _ := utf8.DecodeRuneInString(p.pattern[p.pos+n:])
 		return r
 	}
 	return endOfText
 }
 
-func match(ch rune) {
-	if peek(0) != ch {
+func (p *parser) match(ch rune) {
+	if ch == endOfText || p.peek(0) != ch {
 		panic(utils.ErrUnexpectedSymbol)
 	}
-	pos += utf8.RuneLen(ch)
+	p.pos += utf8.RuneLen(ch)
 }
 
-func next(n int) rune {
+func (p *parser) next(n int) rune {
 	var ch rune
 	for i := 0; i <= n; i++ {
-		ch = peek(i)
-		match(ch)
+		ch = p.peek(i)
+		p.match(ch)
 	}
 	return ch
 }
 
-func stripSpace() {
-	for peek(0) == ' ' {
-		next(0)
+func (p *parser) stripSpace() {
+	for p.peek(0) == ' ' {
+		p.next(0)
 	}
 }
 
-func disjunction() (node *Node) {
-	node = &Node{Label: "Disjunction", Sub: nil}
-	trm := term()
-	if peek(0) == '|' {
-		match('|')
-		dis := disjunction()
-		node.Sub = []*Node{trm, dis}
-	} else {
-		node.Sub = []*Node{trm}
+// leaf returns a node of kind k whose value is the next rune.
+func (p *parser) leaf(k Kind) *Node {
+	start := p.pos
+	return &Node{Kind: k, Value: string(p.next(0)), Start: start, End: p.pos}
+}
+
+// disjunction parses the terms of a disjunction in a loop rather than
+// by recursion and nests them as the grammar does.
+func (p *parser) disjunction() *Node {
+	var nodes []*Node
+	for {
+		node := &Node{Kind: KindDisjunction, Start: p.pos}
+		node.Sub = []*Node{p.term()}
+		nodes = append(nodes, node)
+		if p.peek(0) != '|' {
+			break
+		}
+		p.match('|')
 	}
-	return node
+	return p.chain(nodes)
 }
 
-func term() (node *Node) {
-	node = &Node{Label: "Term", Sub: nil}
-	factr := factor()
-	if peek(0) != endOfText &&
-		!utils.IsAnyOf(peek(0), []rune{'|', ')', ']', '}'}) {
-		trm := term()
-		node.Sub = []*Node{factr, trm}
-	} else {
-		node.Sub = []*Node{factr}
+// term parses the factors of a term in a loop rather than by recursion
+// and nests them as the grammar does.
+func (p *parser) term() *Node {
+	var nodes []*Node
+	for {
+		node := &Node{Kind: KindTerm, Start: p.pos}
+		node.Sub = []*Node{p.factor()}
+		nodes = append(nodes, node)
+		if p.peek(0) == endOfText ||
+			utils.IsAnyOf(p.peek(0), []rune{'|', ')', ']', '}'}) {
+			break
+		}
 	}
-	return node
+	return p.chain(nodes)
+}
+
+// chain makes each of nodes the last child of the one before it,
+// ending them all at the current position, and returns the first.
+func (p *parser) chain(nodes []*Node) *Node {
+	for j := len(nodes) - 1; j >= 0; j-- {
+		nodes[j].End = p.pos
+		if j+1 < len(nodes) {
+			nodes[j].Sub = append(nodes[j].Sub, nodes[j+1])
+		}
+	}
+	return nodes[0]
 }
 
-func factor() (node *Node) {
-	node = &Node{Label: "Factor", Sub: nil}
-	if utils.IsAnyOf(peek(0), []rune{'^', '$'}) {
-		asr := assertion()
+func (p *parser) factor() (node *Node) {
+	node = &Node{Kind: KindFactor, Start: p.pos}
+	if utils.IsAnyOf(p.peek(0), []rune{'^', '$'}) {
+		asr := p.assertion()
 		node.Sub = []*Node{asr}
-	} else if peek(0) == '\\' && (peek(1) == 'b' || peek(1) == 'B') {
-		match('\\')
-		asr := assertion()
+	} else if p.peek(0) == '\\' && (p.peek(1) == 'b' || p.peek(1) == 'B') {
+		if p.posix {
+			panic(utils.ErrInvalidEscape)
+		}
+		p.match('\\')
+		asr := p.assertion()
+		asr.Start = node.Start
 		node.Sub = []*Node{asr}
 	} else {
-		atm := atom()
-		if utils.IsAnyOf(peek(0), []rune{'*', '+', '?', '{'}) {
-			qnt := quantifier()
+		atm := p.atom()
+		if utils.IsAnyOf(p.peek(0), []rune{'*', '+', '?', '{'}) {
+			qnt := p.quantifier()
 			node.Sub = []*Node{atm, qnt}
 		} else {
 			node.Sub = []*Node{atm}
 		}
 	}
+	node.End = p.pos
 	return node
 }
 
-func assertion() (node *Node) {
-	node = &Node{Label: "Assertion",
-		Sub: []*Node{{Label: string(next(0))}}}
-	return node
+func (p *parser) assertion() (node *Node) {
+	return p.leaf(KindAssertion)
 }
 
-func quantifier() (node *Node) {
-	node = &Node{Label: "Quantifier", Sub: nil}
-	switch next(0) {
+func (p *parser) quantifier() (node *Node) {
+	node = &Node{Kind: KindQuantifier, Start: p.pos}
+	bound := func(value string) *Node {
+		return &Node{Kind: KindNumber, Value: value, Start: node.Start, End: p.pos}
+	}
+	switch p.next(0) {
 	default:
 		panic(utils.ErrInvalidRepeatOp)
 	case '*':
 		// Zero or more
-		node.Sub = []*Node{{Label: "0"}, {Label: "-1"}}
+		node.Sub = []*Node{bound("0"), bound("-1")}
 	case '+':
 		// One or more
-		node.Sub = []*Node{{Label: "1"}, {Label: "-1"}}
+		node.Sub = []*Node{bound("1"), bound("-1")}
 	case '?':
 		// Zero or one
-		node.Sub = []*Node{{Label: "0"}, {Label: "1"}}
+		node.Sub = []*Node{bound("0"), bound("1")}
 	case '{':
-		stripSpace()
-		lower := decimalDigits()
-		stripSpace()
-		if peek(0) == ',' {
-			match(',')
-			stripSpace()
-			if peek(0) == '}' {
-				node.Sub = []*Node{lower, {Label: "-1"}}
+		p.stripSpace()
+		lower := p.decimalDigits()
+		p.stripSpace()
+		if p.peek(0) == ',' {
+			p.match(',')
+			p.stripSpace()
+			if p.peek(0) == '}' {
+				node.Sub = []*Node{lower, {Kind: KindNumber, Value: "-1", Start: p.pos, End: p.pos}}
 			} else {
-				upper := decimalDigits()
+				upper := p.decimalDigits()
 				node.Sub = []*Node{lower, upper}
 			}
 		} else {
-			node.Sub = []*Node{lower, lower}
+			upper := *lower
+			node.Sub = []*Node{lower, &upper}
 		}
-		stripSpace()
-		match('}')
+		p.stripSpace()
+		p.match('}')
 	}
+	node.End = p.pos
 	return node
 }
 
-func atom() (node *Node) {
-	node = &Node{Label: "Atom", Sub: nil}
-	switch peek(0) {
+func (p *parser) atom() (node *Node) {
+	node = &Node{Kind: KindAtom, Start: p.pos}
+	switch p.peek(0) {
 	default:
-		lit := anyLiteralExcept([]rune{
+		lit := p.anyLiteralExcept([]rune{
 			'^', '$', '\\', '.', '*', '+', '?',
 			'(', ')', '[', ']', '{', '}', '|'}, utils.ErrUnexpectedSymbol)
 		node.Sub = []*Node{lit}
 	case '.':
-		match('.')
-		node.Sub = []*Node{{Label: "."}}
+		p.match('.')
+		node.Sub = []*Node{{Kind: KindDot, Start: node.Start, End: p.pos}}
 
 	case '\\':
-		match('\\')
-		esc := atomEscape()
+		p.match('\\')
+		esc := p.atomEscape()
+		esc.Start = node.Start
 		node.Sub = []*Node{esc}
 
 	case '[':
-		cls := characterClass()
+		cls := p.characterClass()
 		node.Sub = []*Node{cls}
 
 	case '(':
-		match('(')
-		dis := disjunction()
-		match(')')
+		p.match('(')
+		p.depth++
+		if p.limits.MaxDepth > 0 && p.depth > p.limits.MaxDepth {
+			panic(utils.ErrNestingTooDeep)
+		}
+		dis := p.disjunction()
+		p.depth--
+		p.match(')')
 		node.Sub = []*Node{dis}
 
 	}
+	node.End = p.pos
 	return node
 }
 
-func atomEscape() (node *Node) {
-	switch peek(0) {
+// atomEscape parses the escape following a backslash. The span of the
+// returned node starts after the backslash; callers extend it.
+func (p *parser) atomEscape() (node *Node) {
+	start := p.pos
+	switch p.peek(0) {
+	default:
+		panic(utils.ErrInvalidEscape)
+	case '^', '$', '\\', '.', '*', '+', '?', '(', ')', '[', ']', '{', '}', '|', '-':
+		node = p.leaf(KindLiteral)
 	case 'f', 'n', 'r', 't', 'v':
-		node = &Node{Label: "Control",
-			Sub: []*Node{{Label: string(next(0))}}}
+		node = p.leaf(KindControl)
 	case 'd', 'D', 's', 'S', 'w', 'W':
-		node = &Node{Label: "Perl",
-			Sub: []*Node{{Label: string(next(0))}}}
+		if p.posix {
+			panic(utils.ErrInvalidEscape)
+		}
+		node = p.leaf(KindPerl)
 	case 'x':
-		match('x')
-		hex := hexSequence()
-		node = &Node{Label: "HexSeq", Sub: []*Node{hex}}
+		p.match('x')
+		node = &Node{Kind: KindHexSeq, Value: p.hexSequence()}
 	case 'p', 'P':
-		uni := unicodeSequence()
-		node = &Node{Label: "UniSeq", Sub: []*Node{uni}}
+		if p.posix {
+			panic(utils.ErrInvalidEscape)
+		}
+		node = &Node{Kind: KindUniSeq, Value: p.unicodeSequence()}
 	}
+	node.Start, node.End = start, p.pos
 	return node
 }
 
-func hexSequence() (node *Node) {
-	match('{')
-	node = &Node{Label: ""}
-	for utils.IsAnyOf(peek(0), []rune{
+func (p *parser) hexSequence() (str string) {
+	p.match('{')
+	for utils.IsAnyOf(p.peek(0), []rune{
 		'0', '1', '2', '3', '4', '5', '6', '7',
 		'8', '9', 'a', 'b', 'c', 'd', 'e', 'f',
 		'A', 'B', 'C', 'D', 'E', 'F',
 	}) {
-		node.Label += string(next(0))
+		str += string(p.next(0))
 	}
-	match('}')
-	return node
+	p.match('}')
+	return str
 }
 
-func unicodeSequence() (node *Node) {
-	if next(0) == 'p' {
-		node = &Node{Label: ""}
-	} else {
-		node = &Node{Label: "^"}
+func (p *parser) unicodeSequence() (str string) {
+	if p.next(0) == 'P' {
+		str = "^"
 	}
-	match('{')
-	if utils.IsAnyOf(peek(0), []rune{
+	p.match('{')
+	if utils.IsAnyOf(p.peek(0), []rune{
 		'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
 		'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
 	}) {
-		node.Label += string(next(0))
+		str += string(p.next(0))
 	}
-	if utils.IsAnyOf(peek(0), []rune{
+	if utils.IsAnyOf(p.peek(0), []rune{
 		'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm',
 		'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z',
 	}) {
-		node.Label += string(next(0))
+		str += string(p.next(0))
 	}
-	match('}')
-	return node
+	p.match('}')
+	return str
 }
 
-func characterClass() (node *Node) {
-	match('[')
-	node = &Node{Label: "Class", Sub: nil}
-	for peek(0) != ']' {
-		clr := classRange()
+func (p *parser) characterClass() (node *Node) {
+	node = &Node{Kind: KindClass, Start: p.pos}
+	p.match('[')
+	if p.peek(0) == '^' {
+		start := p.pos
+		p.match('^')
+		node.Sub = append(node.Sub, &Node{Kind: KindNegation, Start: start, End: p.pos})
+	}
+	for p.peek(0) != ']' {
+		clr := p.classRange()
 		if len(clr.Sub) == 1 {
 			clr = clr.Sub[0]
 		}
 		node.Sub = append(node.Sub, clr)
 	}
-	match(']')
+	p.match(']')
+	node.End = p.pos
 	return node
 }
 
-func classRange() (node *Node) {
-	node = &Node{Label: "ClassRange", Sub: nil}
-	cla0 := classAtom()
+func (p *parser) classRange() (node *Node) {
+	node = &Node{Kind: KindClassRange, Start: p.pos}
+	cla0 := p.classAtom()
 	node.Sub = append(node.Sub, cla0)
-	if peek(0) == '-' {
-		match('-')
-		cla1 := classAtom()
-		if cla0.Label == "Perl" || cla0.Label == "UniSeq" ||
-			cla1.Label == "Perl" || cla1.Label == "UniSeq" {
+	if p.peek(0) == '-' {
+		p.match('-')
+		cla1 := p.classAtom()
+		if cla0.Kind == KindPerl || cla0.Kind == KindUniSeq ||
+			cla1.Kind == KindPerl || cla1.Kind == KindUniSeq {
 			panic(utils.ErrRangeWithShorthand)
 		}
 		node.Sub = append(node.Sub, cla1)
 	}
+	node.End = p.pos
 	return node
 }
 
-func classAtom() (node *Node) {
-	if peek(0) == '\\' {
-		match('\\')
-		node = atomEscape()
+func (p *parser) classAtom() (node *Node) {
+	if p.peek(0) == '\\' {
+		start := p.pos
+		p.match('\\')
+		node = p.atomEscape()
+		node.Start = start
 	} else {
-		node = anyLiteralExcept([]rune{'\\', ']', '-'}, utils.ErrInvalidCharClass)
+		node = p.anyLiteralExcept([]rune{'\\', ']', '-'}, utils.ErrInvalidCharClass)
 	}
 	return node
 }
 
-func decimalDigits() (node *Node) {
-	if !utils.IsAnyOf(peek(0),
+func (p *parser) decimalDigits() (node *Node) {
+	if !utils.IsAnyOf(p.peek(0),
 		[]rune{'0', '1', '2', '3', '4', '5', '6', '7', '8', '9'}) {
 		panic(utils.ErrInvalidRepeatSize)
 	}
-	node = &Node{Label: ""}
-	for utils.IsAnyOf(peek(0),
+	node = &Node{Kind: KindNumber, Start: p.pos}
+	for utils.IsAnyOf(p.peek(0),
 		[]rune{'0', '1', '2', '3', '4', '5', '6', '7', '8', '9'}) {
-		node.Label += string(next(0))
+		node.Value += string(p.next(0))
 	}
+	node.End = p.pos
 	return node
 }
 
-func anyLiteralExcept(rs []rune, err utils.ErrorCode) (node *Node) {
-	if utils.IsAnyOf(peek(0), rs) {
+func (p *parser) anyLiteralExcept(rs []rune, err utils.ErrorCode) (node *Node) {
+	if utils.IsAnyOf(p.peek(0), rs) {
 		panic(err)
 	}
-	node = &Node{Label: "Literal",
-		Sub: []*Node{{Label: string(next(0))}}}
+	return p.leaf(KindLiteral)
+}
+
+func parse(regex string, posix bool, lim Limits) *Node {
+	if lim.MaxLen > 0 && len(regex) > lim.MaxLen {
+		panic(utils.ErrPatternTooLong)
+	}
+	p := &parser{pattern: regex, posix: posix, limits: lim}
+
+	node := p.disjunction()
+	if p.pos < len(p.pattern) {
+		panic(utils.ErrUnexpectedSymbol)
+	}
 	return node
 }
 
 func ToSyntaxTree(regex string) *Node {
-	pattern = regex
-	pos = 0
+	return ToSyntaxTreeLimits(regex, Limits{})
+}
+
+// ToSyntaxTreeLimits is like ToSyntaxTree but rejects patterns
+// beyond lim.
+func ToSyntaxTreeLimits(regex string, lim Limits) *Node {
+	return parse(regex, false, lim)
+}
+
+// ToSyntaxTreePOSIX is like ToSyntaxTree but restricts the pattern
+// to POSIX ERE syntax. Perl classes, Unicode classes and word boundary
+// assertions are rejected.
+func ToSyntaxTreePOSIX(regex string) *Node {
+	return ToSyntaxTreePOSIXLimits(regex, Limits{})
+}
 
-	return disjunction()
+// ToSyntaxTreePOSIXLimits is like ToSyntaxTreePOSIX but rejects
+// patterns beyond lim.
+func ToSyntaxTreePOSIXLimits(regex string, lim Limits) *Node {
+	return parse(regex, true, lim)
 }
@@ -17,12 +17,18 @@ type ErrorCode string
 const (
 	ErrInvalidCharClass   ErrorCode = "invalid character class"
 	ErrInvalidAssertion   ErrorCode = "invalid assertion"
+	ErrInvalidEscape      ErrorCode = "invalid escape sequence"
 	ErrRangeWithShorthand ErrorCode = "cannot create a range with shorthand escape sequences"
 	ErrInvalidClassRange  ErrorCode = "invalid character class range"
 	ErrInvalidRepeatOp    ErrorCode = "invalid repetition operator"
 	ErrEmptyRegexPattern  ErrorCode = "regex pattern is empty"
 	ErrInvalidRepeatSize  ErrorCode = "invalid repeat count"
 	ErrUnexpectedSymbol   ErrorCode = "unexpected symbol"
+	ErrRepeatTooLarge     ErrorCode = "repeat count exceeds limit"
+	ErrNestingTooDeep     ErrorCode = "expression nests too deeply"
+	ErrProgramTooLarge    ErrorCode = "expression too large"
+	ErrTooManyClasses     ErrorCode = "too many character classes"
+	ErrPatternTooLong     ErrorCode = "expression too long"
 )
 
 func (e ErrorCode) String() string {